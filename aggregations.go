@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// numericValues convert list values to decimals, nil and empty strings are skipped
+func numericValues(name string, list []interface{}) ([]decimal.Decimal, error) {
+	result := make([]decimal.Decimal, 0, len(list))
+	for _, item := range list {
+		if item == nil || item == "" {
+			continue
+		}
+		value, ok := numericValue(item)
+		if !ok {
+			return nil, fmt.Errorf("%s: value %v is not a number", name, item)
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+// sumDecimal sum of decimals
+func sumDecimal(values []decimal.Decimal) decimal.Decimal {
+	total := decimal.Zero
+	for _, value := range values {
+		total = total.Add(value)
+	}
+	return total
+}
+
+// avgDecimal average of decimals, zero for empty list
+func avgDecimal(values []decimal.Decimal) decimal.Decimal {
+	if len(values) == 0 {
+		return decimal.Zero
+	}
+	return sumDecimal(values).Div(decimal.NewFromInt(int64(len(values))))
+}
+
+// sum of list values with decimal precision {{sum (pluck "val3" .TOP_LEVEL.DATA_LINE)}}
+func sum(list interface{}) (decimal.Decimal, error) {
+	values, err := numericValues("sum", toList(list))
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return sumDecimal(values), nil
+}
+
+// avg average of list values with decimal precision {{avg (pluck "val3" .TOP_LEVEL.DATA_LINE)}}
+func avg(list interface{}) (decimal.Decimal, error) {
+	values, err := numericValues("avg", toList(list))
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return avgDecimal(values), nil
+}
+
+// count items of list (single XML record is counted as 1, nil as 0)
+func count(list interface{}) int {
+	return len(toList(list))
+}
+
+// sumBy sum of key path values {{sumBy "val3" .TOP_LEVEL.DATA_LINE | toDecimalString}}, items without key are skipped
+func sumBy(path string, list interface{}) (decimal.Decimal, error) {
+	values, err := numericValues("sumBy", pluck(path, list))
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return sumDecimal(values), nil
+}
+
+// avgBy average of key path values {{avgBy "val3" .TOP_LEVEL.DATA_LINE}}, items without key are skipped
+func avgBy(path string, list interface{}) (decimal.Decimal, error) {
+	values, err := numericValues("avgBy", pluck(path, list))
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return avgDecimal(values), nil
+}
+
+// minBy item with lowest key path value {{(minBy "val1" .TOP_LEVEL.DATA_LINE).Employee}}, nil for empty list
+func minBy(path string, list interface{}) interface{} {
+	return extremeBy(path, list, -1)
+}
+
+// maxBy item with highest key path value {{(maxBy "Trans_Date" .TOP_LEVEL.DATA_LINE).val1}}, nil for empty list
+func maxBy(path string, list interface{}) interface{} {
+	return extremeBy(path, list, 1)
+}
+
+// extremeBy find first item with lowest (direction -1) or highest (direction 1) key path value
+func extremeBy(path string, list interface{}, direction int) interface{} {
+	var result, resultValue interface{}
+	for _, item := range toList(list) {
+		value, ok := pathValue(item, path)
+		if !ok || value == nil {
+			continue
+		}
+		if result == nil || compareValues(value, resultValue)*direction > 0 {
+			result, resultValue = item, value
+		}
+	}
+	return result
+}
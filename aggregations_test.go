@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+	if result, err := sum([]interface{}{0.1, 0.2, "", nil}); err != nil || result.String() != "0.3" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := sum([]interface{}{"18.3285", "5.67343", 2.97984}); err != nil || result.String() != "26.98177" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := sum([]interface{}{"1", "abc"}); err == nil || !strings.Contains(err.Error(), "sum: value abc is not a number") {
+		t.Errorf("result: %v", err)
+	}
+	if result, _ := sum(nil); !result.IsZero() {
+		t.Errorf("result: %v", result)
+	}
+}
+
+func TestAvg(t *testing.T) {
+	if result, err := avg([]interface{}{"1", 2, 3.0}); err != nil || result.String() != "2" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, _ := avg([]interface{}{}); !result.IsZero() {
+		t.Errorf("result: %v", result)
+	}
+	if _, err := avg([]interface{}{true}); err == nil {
+		t.Errorf("result: expected error")
+	}
+}
+
+func TestCount(t *testing.T) {
+	if result := count(testLines); result != 3 {
+		t.Errorf("result: %v", result)
+	}
+	if result := count(map[string]interface{}{"a": 1}); result != 1 {
+		t.Errorf("result: %v", result)
+	}
+	if result := count(nil); result != 0 {
+		t.Errorf("result: %v", result)
+	}
+}
+
+func TestSumByAvgBy(t *testing.T) {
+	if result, err := sumBy("val1", testLines); err != nil || result.String() != "62" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := avgBy("val1", testLines); err != nil || result.StringFixed(2) != "20.67" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := sumBy("name", testLines); err == nil || !strings.Contains(err.Error(), "sumBy:") {
+		t.Errorf("result: %v", err)
+	}
+	if err := runtv(`{{sumBy "val1" .lines | toDecimalString}}`, "62", map[string]interface{}{"lines": testLines}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMinByMaxBy(t *testing.T) {
+	if result := minBy("val1", testLines); names([]interface{}{result}) != "ACME" {
+		t.Errorf("result: %v", result)
+	}
+	if result := maxBy("val1", testLines); names([]interface{}{result}) != "Globex" {
+		t.Errorf("result: %v", result)
+	}
+	if result := maxBy("date", testLines); names([]interface{}{result}) != "Initech" {
+		t.Errorf("result: %v", result)
+	}
+	if result := minBy("missing", testLines); result != nil {
+		t.Errorf("result: %v", result)
+	}
+}
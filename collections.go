@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// dateLayouts layouts used to recognize dates when comparing values (sortBy, where)
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// toList convert value to []interface{}. Any slice is converted, single value (e.g. XML with one record) is wrapped, nil is empty list
+func toList(v interface{}) []interface{} {
+	if v == nil {
+		return []interface{}{}
+	}
+	if list, ok := v.([]interface{}); ok {
+		return list
+	}
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+		list := make([]interface{}, value.Len())
+		for i := range list {
+			list[i] = value.Index(i).Interface()
+		}
+		return list
+	}
+	return []interface{}{v}
+}
+
+// pathValue get value by dotted key path e.g. "Employee.-ID" or "items.0.name", empty path returns item itself
+func pathValue(item interface{}, path string) (interface{}, bool) {
+	if path == "" || path == "." {
+		return item, true
+	}
+	current := item
+	for _, key := range strings.Split(path, ".") {
+		value := reflect.ValueOf(current)
+		for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				return nil, false
+			}
+			value = value.Elem()
+		}
+		switch value.Kind() {
+		case reflect.Map:
+			if value.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			next := value.MapIndex(reflect.ValueOf(key).Convert(value.Type().Key()))
+			if !next.IsValid() {
+				return nil, false
+			}
+			current = next.Interface()
+		case reflect.Slice, reflect.Array:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= value.Len() {
+				return nil, false
+			}
+			current = value.Index(index).Interface()
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// numericValue get decimal value of number or numeric string
+func numericValue(v interface{}) (decimal.Decimal, bool) {
+	switch value := v.(type) {
+	case decimal.Decimal:
+		return value, true
+	case string:
+		d, err := decimal.NewFromString(strings.TrimSpace(value))
+		return d, err == nil
+	case bool, nil:
+		return decimal.Zero, false
+	}
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return decimal.NewFromInt(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return decimal.NewFromBigInt(new(big.Int).SetUint64(value.Uint()), 0), true
+	case reflect.Float32, reflect.Float64:
+		return decimal.NewFromFloat(value.Float()), true
+	}
+	return decimal.Zero, false
+}
+
+// dateValue get time of time.Time or date string in one of dateLayouts
+func dateValue(v interface{}) (time.Time, bool) {
+	switch value := v.(type) {
+	case time.Time:
+		return value, true
+	case string:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Type ranks of compared values, values of different types are ordered by rank (nil < numbers < dates < strings)
+const (
+	rankNil = iota
+	rankNumber
+	rankDate
+	rankString
+)
+
+// compareKey value prepared for comparison, parsed once per value
+type compareKey struct {
+	rank   int
+	number decimal.Decimal
+	date   time.Time
+	text   string
+}
+
+// newCompareKey parse value as number, date or string (in this order)
+func newCompareKey(v interface{}) compareKey {
+	if v == nil {
+		return compareKey{rank: rankNil}
+	}
+	if number, ok := numericValue(v); ok {
+		return compareKey{rank: rankNumber, number: number}
+	}
+	if date, ok := dateValue(v); ok {
+		return compareKey{rank: rankDate, date: date}
+	}
+	return compareKey{rank: rankString, text: toString(v)}
+}
+
+// compare keys by type rank and then by value
+func (k compareKey) compare(other compareKey) int {
+	if k.rank != other.rank {
+		if k.rank < other.rank {
+			return -1
+		}
+		return 1
+	}
+	switch k.rank {
+	case rankNumber:
+		return k.number.Cmp(other.number)
+	case rankDate:
+		return k.date.Compare(other.date)
+	case rankString:
+		return strings.Compare(k.text, other.text)
+	}
+	return 0
+}
+
+// compareValues compare values as numbers, dates or strings. Values of different types are ordered nil < numbers < dates < strings
+func compareValues(a, b interface{}) int {
+	return newCompareKey(a).compare(newCompareKey(b))
+}
+
+// sortBy sort list by key path {{sortBy "val1" .TOP_LEVEL.DATA_LINE}} or with order {{.TOP_LEVEL.DATA_LINE | sortBy "Trans_Date" "desc"}}
+// Numbers, numeric strings and dates are compared by value. Empty key sorts list of values
+func sortBy(path string, args ...interface{}) ([]interface{}, error) {
+	var list interface{}
+	desc := false
+	switch len(args) {
+	case 1:
+		list = args[0]
+	case 2:
+		switch strings.ToLower(toString(args[0])) {
+		case "asc":
+		case "desc":
+			desc = true
+		default:
+			return nil, fmt.Errorf("sortBy: unknown order %v (accepted values are asc, desc)", args[0])
+		}
+		list = args[1]
+	default:
+		return nil, fmt.Errorf("sortBy: expected key, optional order and list")
+	}
+	items := toList(list)
+	// keys are parsed once per item
+	type sortItem struct {
+		key   compareKey
+		value interface{}
+	}
+	keyed := make([]sortItem, len(items))
+	for i, item := range items {
+		value, _ := pathValue(item, path)
+		keyed[i] = sortItem{key: newCompareKey(value), value: item}
+	}
+	sort.SliceStable(keyed, func(i, j int) bool {
+		if desc {
+			return keyed[i].key.compare(keyed[j].key) > 0
+		}
+		return keyed[i].key.compare(keyed[j].key) < 0
+	})
+	sorted := make([]interface{}, len(keyed))
+	for i, item := range keyed {
+		sorted[i] = item.value
+	}
+	return sorted, nil
+}
+
+// where filter list by key path, operator and value {{where "val1" "gt" 10 .TOP_LEVEL.DATA_LINE}}
+// Operators: eq, ne, gt, ge, lt, le (or ==, !=, >, >=, <, <=), contains (substring or list item), in (value is list)
+func where(path string, operator string, value interface{}, list interface{}) ([]interface{}, error) {
+	result := make([]interface{}, 0)
+	for _, item := range toList(list) {
+		field, ok := pathValue(item, path)
+		if !ok {
+			continue
+		}
+		var match bool
+		switch operator {
+		case "eq", "==":
+			match = compareValues(field, value) == 0
+		case "ne", "!=":
+			match = compareValues(field, value) != 0
+		case "gt", ">":
+			match = compareValues(field, value) > 0
+		case "ge", ">=":
+			match = compareValues(field, value) >= 0
+		case "lt", "<":
+			match = compareValues(field, value) < 0
+		case "le", "<=":
+			match = compareValues(field, value) <= 0
+		case "contains":
+			if reflect.ValueOf(field).Kind() == reflect.Slice {
+				match = inList(value, field)
+			} else {
+				match = strings.Contains(toString(field), toString(value))
+			}
+		case "in":
+			match = inList(field, value)
+		default:
+			return nil, fmt.Errorf("where: unknown operator %s (accepted values are eq, ne, gt, ge, lt, le, contains, in)", operator)
+		}
+		if match {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// inList check if list contains value (compared by compareValues)
+func inList(value interface{}, list interface{}) bool {
+	for _, item := range toList(list) {
+		if compareValues(item, value) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// groupBy group list items by key path value {{range $key, $items := groupBy "Employee.-ID" .TOP_LEVEL.DATA_LINE}}
+func groupBy(path string, list interface{}) map[string][]interface{} {
+	groups := make(map[string][]interface{})
+	for _, item := range toList(list) {
+		field, _ := pathValue(item, path)
+		key := toString(field)
+		groups[key] = append(groups[key], item)
+	}
+	return groups
+}
+
+// uniq remove duplicate values from list, first occurrence is kept
+func uniq(list interface{}) []interface{} {
+	seen := make(map[string]bool)
+	result := make([]interface{}, 0)
+	for _, item := range toList(list) {
+		key := fmt.Sprintf("%T:%v", item, item)
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// pluck get values of key path from list items {{pluck "val1" .TOP_LEVEL.DATA_LINE}}, items without key are skipped
+func pluck(path string, list interface{}) []interface{} {
+	result := make([]interface{}, 0)
+	for _, item := range toList(list) {
+		if field, ok := pathValue(item, path); ok {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+// first item of list (nil if list is empty)
+func first(list interface{}) interface{} {
+	items := toList(list)
+	if len(items) == 0 {
+		return nil
+	}
+	return items[0]
+}
+
+// last item of list (nil if list is empty)
+func last(list interface{}) interface{} {
+	items := toList(list)
+	if len(items) == 0 {
+		return nil
+	}
+	return items[len(items)-1]
+}
+
+// reverse order of list
+func reverse(list interface{}) []interface{} {
+	items := toList(list)
+	result := make([]interface{}, len(items))
+	for i, item := range items {
+		result[len(items)-1-i] = item
+	}
+	return result
+}
+
+// concat join lists {{concat .list1 .list2}}
+func concat(lists ...interface{}) []interface{} {
+	result := make([]interface{}, 0)
+	for _, list := range lists {
+		result = append(result, toList(list)...)
+	}
+	return result
+}
+
+// keys of map sorted alphabetically
+func keys(m interface{}) ([]string, error) {
+	value := reflect.ValueOf(m)
+	if value.Kind() != reflect.Map {
+		return nil, fmt.Errorf("keys: expected map, got %T", m)
+	}
+	result := make([]string, 0, value.Len())
+	for _, key := range value.MapKeys() {
+		result = append(result, toString(key.Interface()))
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// values of map ordered by sorted keys
+func values(m interface{}) ([]interface{}, error) {
+	value := reflect.ValueOf(m)
+	if value.Kind() != reflect.Map {
+		return nil, fmt.Errorf("values: expected map, got %T", m)
+	}
+	mapKeys := value.MapKeys()
+	sort.Slice(mapKeys, func(i, j int) bool { return toString(mapKeys[i].Interface()) < toString(mapKeys[j].Interface()) })
+	result := make([]interface{}, len(mapKeys))
+	for i, key := range mapKeys {
+		result[i] = value.MapIndex(key).Interface()
+	}
+	return result, nil
+}
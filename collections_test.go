@@ -0,0 +1,171 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+var testLines = []interface{}{
+	map[string]interface{}{"name": "ACME", "val1": "5", "date": "2021-05-23", "tags": []interface{}{"a", "b"}, "Employee": map[string]interface{}{"-ID": "0021"}},
+	map[string]interface{}{"name": "Globex", "val1": "43", "date": "2021-05-21", "tags": []interface{}{"b"}, "Employee": map[string]interface{}{"-ID": "0023"}},
+	map[string]interface{}{"name": "Initech", "val1": "14", "date": "2021-06-01", "Employee": map[string]interface{}{"-ID": "0021"}},
+}
+
+func names(list []interface{}) string {
+	result := make([]string, len(list))
+	for i, item := range list {
+		result[i] = toString(item.(map[string]interface{})["name"])
+	}
+	return strings.Join(result, ",")
+}
+
+func TestToList(t *testing.T) {
+	if result := toList(nil); len(result) != 0 {
+		t.Errorf("result: %v", result)
+	}
+	if result := toList([]map[string]interface{}{{"a": 1}}); len(result) != 1 {
+		t.Errorf("result: %v", result)
+	}
+	if result := toList(map[string]interface{}{"a": 1}); len(result) != 1 {
+		t.Errorf("result: %v", result)
+	}
+}
+
+func TestPathValue(t *testing.T) {
+	data := map[string]interface{}{"items": []interface{}{map[string]interface{}{"name": "ACME"}}}
+	if result, ok := pathValue(data, "items.0.name"); !ok || result != "ACME" {
+		t.Errorf("result: %v", result)
+	}
+	if _, ok := pathValue(data, "items.1.name"); ok {
+		t.Errorf("result: expected missing")
+	}
+	if result, ok := pathValue(testLines[0], "Employee.-ID"); !ok || result != "0021" {
+		t.Errorf("result: %v", result)
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		a, b     interface{}
+		expected int
+	}{
+		{"5", "43", -1},
+		{"5", 5, 0},
+		{decimal.RequireFromString("1.10"), 1.1, 0},
+		{"2021-05-23", "2021-05-21", 1},
+		{"b", "a", 1},
+		{nil, "a", -1},
+		// Mixed types are ordered by type: nil < numbers < dates < strings
+		{"abc", 5, 1},
+		{"2021-05-23", "10", 1},
+		{"2021-05-23", "abc", -1},
+	}
+	for _, test := range tests {
+		if result := compareValues(test.a, test.b); result != test.expected {
+			t.Errorf("compare %v %v result: %v", test.a, test.b, result)
+		}
+	}
+}
+
+func TestSortByMixedTypes(t *testing.T) {
+	// Order doesn't depend on input order (comparison is transitive across types)
+	expected := []interface{}{nil, 2, "10", "2021-05-23", "abc", "b"}
+	for _, list := range [][]interface{}{
+		{"b", "10", nil, "2021-05-23", 2, "abc"},
+		{"abc", 2, "2021-05-23", "b", nil, "10"},
+	} {
+		if result, err := sortBy("", list); err != nil || !reflect.DeepEqual(result, expected) {
+			t.Errorf("result: %v %v", result, err)
+		}
+	}
+}
+
+func TestSortBy(t *testing.T) {
+	if result, err := sortBy("val1", testLines); err != nil || names(result) != "ACME,Initech,Globex" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := sortBy("date", "desc", testLines); err != nil || names(result) != "Initech,ACME,Globex" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if names(testLines) != "ACME,Globex,Initech" {
+		t.Errorf("input list must not be modified")
+	}
+	if _, err := sortBy("val1", "up", testLines); err == nil {
+		t.Errorf("result: expected error")
+	}
+	if result, _ := sortBy("", []interface{}{3, 1, 2}); !reflect.DeepEqual(result, []interface{}{1, 2, 3}) {
+		t.Errorf("result: %v", result)
+	}
+	if err := runtv(`{{range .lines | sortBy "val1" "desc"}}{{.name}} {{end}}`, "Globex Initech ACME ", map[string]interface{}{"lines": testLines}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWhere(t *testing.T) {
+	tests := []struct {
+		path, operator string
+		value          interface{}
+		expected       string
+	}{
+		{"val1", "gt", 10, "Globex,Initech"},
+		{"val1", "<=", "14", "ACME,Initech"},
+		{"Employee.-ID", "eq", "0021", "ACME,Initech"},
+		{"name", "ne", "ACME", "Globex,Initech"},
+		{"name", "contains", "ni", "Initech"},
+		{"tags", "contains", "a", "ACME"},
+		{"name", "in", []interface{}{"ACME", "Globex"}, "ACME,Globex"},
+		{"date", "ge", "2021-05-23", "ACME,Initech"},
+	}
+	for _, test := range tests {
+		if result, err := where(test.path, test.operator, test.value, testLines); err != nil || names(result) != test.expected {
+			t.Errorf("where %s %s %v result: %v %v", test.path, test.operator, test.value, names(result), err)
+		}
+	}
+	if _, err := where("val1", "like", 1, testLines); err == nil {
+		t.Errorf("result: expected error")
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	groups := groupBy("Employee.-ID", testLines)
+	if len(groups) != 2 || names(groups["0021"]) != "ACME,Initech" || names(groups["0023"]) != "Globex" {
+		t.Errorf("result: %v", groups)
+	}
+}
+
+func TestListFunctions(t *testing.T) {
+	if result := uniq([]interface{}{"a", "b", "a", 1, "1"}); !reflect.DeepEqual(result, []interface{}{"a", "b", 1, "1"}) {
+		t.Errorf("result: %v", result)
+	}
+	if result := pluck("Employee.-ID", testLines); !reflect.DeepEqual(result, []interface{}{"0021", "0023", "0021"}) {
+		t.Errorf("result: %v", result)
+	}
+	if result := first(testLines); names([]interface{}{result}) != "ACME" {
+		t.Errorf("result: %v", result)
+	}
+	if result := last(testLines); names([]interface{}{result}) != "Initech" {
+		t.Errorf("result: %v", result)
+	}
+	if first(nil) != nil || last([]interface{}{}) != nil {
+		t.Errorf("result: expected nil")
+	}
+	if result := reverse(testLines); names(result) != "Initech,Globex,ACME" {
+		t.Errorf("result: %v", result)
+	}
+	if result := concat([]interface{}{1}, nil, []string{"a", "b"}, 2); !reflect.DeepEqual(result, []interface{}{1, "a", "b", 2}) {
+		t.Errorf("result: %v", result)
+	}
+	data := map[string]interface{}{"b": 2, "a": 1}
+	if result, _ := keys(data); !reflect.DeepEqual(result, []string{"a", "b"}) {
+		t.Errorf("result: %v", result)
+	}
+	if result, _ := values(data); !reflect.DeepEqual(result, []interface{}{1, 2}) {
+		t.Errorf("result: %v", result)
+	}
+	if _, err := keys("a"); err == nil {
+		t.Errorf("result: expected error")
+	}
+}
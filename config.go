@@ -0,0 +1,257 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// tJob job definition in project config file (bafi.yaml)
+type tJob struct {
+	Input         string                   `yaml:"input"`  // input file or "?files.yaml" list
+	Inputs        []map[string]interface{} `yaml:"inputs"` // inline list of input files (file, format, label, schema)
+	Format        string                   `yaml:"format"`
+	Delimiter     string                   `yaml:"delimiter"`
+	Template      string                   `yaml:"template"`
+	TemplateDir   string                   `yaml:"templateDir"`
+	Output        string                   `yaml:"output"`
+	OutputDir     string                   `yaml:"outputDir"`
+	OutputMode    string                   `yaml:"outputMode"`
+	Manifest      string                   `yaml:"manifest"`
+	Perm          string                   `yaml:"perm"`
+	Mkdir         bool                     `yaml:"mkdir"`
+	OnFail        string                   `yaml:"onFail"`
+	Vars          map[string]string        `yaml:"vars"`
+	EnvAllow      []string                 `yaml:"envAllow"`
+	Keys          tList                    `yaml:"keys"`
+	Lua           tList                    `yaml:"lua"`
+	LuaPath       string                   `yaml:"luaPath"`
+	LuaSandbox    bool                     `yaml:"luaSandbox"`
+	LuaTimeout    time.Duration            `yaml:"luaTimeout"`
+	LuaPre        string                   `yaml:"luaPre"`
+	LuaPost       string                   `yaml:"luaPost"`
+	JS            tList                    `yaml:"js"`
+	JSTimeout     time.Duration            `yaml:"jsTimeout"`
+	JSSandbox     bool                     `yaml:"jsSandbox"`
+	Query         string                   `yaml:"query"`
+	Seed          *int64                   `yaml:"seed"` // nil if not defined
+	Now           string                   `yaml:"now"`
+	UUIDNamespace string                   `yaml:"uuidNamespace"`
+	Deterministic bool                     `yaml:"deterministic"`
+	Schema        string                   `yaml:"schema"`
+	OutputFormat  string                   `yaml:"outputFormat"`
+	OutputSchema  string                   `yaml:"outputSchema"`
+	Strict        bool                     `yaml:"strict"`
+}
+
+// tConfig project config file, jobs are kept in order as defined in file
+type tConfig struct {
+	Jobs yaml.Node `yaml:"jobs"`
+}
+
+// runJobs run jobs from project config file. bafi run [-c bafi.yaml] jobName1 jobName2 ... or bafi run -all
+func runJobs(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	configFile := flags.String("c", "bafi.yaml", "project config file with jobs definition")
+	runAll := flags.Bool("all", false, "run all jobs")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	names, jobs, err := readConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	selected := flags.Args()
+	if *runAll {
+		selected = names
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("runJobs: job name must be defined: bafi run jobName (or bafi run -all), available jobs: %s", strings.Join(names, ", "))
+	}
+	for _, name := range selected {
+		if _, ok := jobs[name]; !ok {
+			return fmt.Errorf("runJobs: unknown job %s, available jobs: %s", name, strings.Join(names, ", "))
+		}
+	}
+	// Relative paths in jobs are relative to config file directory
+	dir := filepath.Dir(*configFile)
+	failed := make([]string, 0)
+	for _, name := range selected {
+		if err := runJob(jobs[name], dir); err != nil {
+			failed = append(failed, fmt.Sprintf("job %s: %s", name, err.Error()))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("runJobs: %d job(s) failed\r\n%s", len(failed), strings.Join(failed, "\r\n"))
+	}
+	return nil
+}
+
+// readConfig read project config file and return job names in order as defined in file
+func readConfig(configFile string) ([]string, map[string]tJob, error) {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("readConfig: %s", err.Error())
+	}
+	var config tConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, nil, fmt.Errorf("readConfig: %s", err.Error())
+	}
+	if config.Jobs.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("readConfig: jobs must be defined as map of named jobs")
+	}
+	names := make([]string, 0)
+	jobs := make(map[string]tJob)
+	for i := 0; i+1 < len(config.Jobs.Content); i += 2 {
+		var job tJob
+		name := config.Jobs.Content[i].Value
+		if err := config.Jobs.Content[i+1].Decode(&job); err != nil {
+			return nil, nil, fmt.Errorf("readConfig: job %s: %s", name, err.Error())
+		}
+		if err := checkInputList(job.Inputs); err != nil {
+			return nil, nil, fmt.Errorf("readConfig: job %s: %s", name, err.Error())
+		}
+		names = append(names, name)
+		jobs[name] = job
+	}
+	return names, jobs, nil
+}
+
+// runJob process single job, relative paths are resolved from dir
+func runJob(job tJob, dir string) error {
+	if job.Template == "" && job.Query == "" {
+		return fmt.Errorf("template or query must be defined")
+	}
+	job, err := job.resolvePaths(dir)
+	if err != nil {
+		return err
+	}
+	return processTemplate(job.params())
+}
+
+// resolvePaths return copy of job with relative paths resolved from dir (working directory of process is not changed).
+// Files of "?" input list are resolved from dir as well, default lua and js files are searched in dir
+func (job tJob) resolvePaths(dir string) (tJob, error) {
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	if strings.HasPrefix(job.Input, "?") {
+		job.Input = "?" + resolve(job.Input[1:])
+		_, files, err := getInputData(&job.Input)
+		if err != nil {
+			return job, err
+		}
+		if err := checkInputList(files); err != nil {
+			return job, err
+		}
+		job.Inputs = files
+	} else {
+		job.Input = resolve(job.Input)
+	}
+	inputs := make([]map[string]interface{}, 0, len(job.Inputs))
+	for _, file := range job.Inputs {
+		input := make(map[string]interface{}, len(file))
+		for key, value := range file {
+			input[key] = value
+		}
+		input["file"] = resolve(file["file"].(string))
+		if schema, ok := file["schema"].(string); ok {
+			input["schema"] = resolve(schema)
+		}
+		inputs = append(inputs, input)
+	}
+	job.Inputs = inputs
+	if !strings.HasPrefix(job.Template, "?") {
+		job.Template = resolve(job.Template)
+	}
+	if job.Output == "" && job.OutputDir == "" {
+		job.OutputDir = dir
+	}
+	job.TemplateDir = resolve(job.TemplateDir)
+	job.Output = resolve(job.Output)
+	job.OutputDir = resolve(job.OutputDir)
+	job.Manifest = resolve(job.Manifest)
+	job.Schema = resolve(job.Schema)
+	job.OutputSchema = resolve(job.OutputSchema)
+	keys := make(tList, 0, len(job.Keys))
+	for _, path := range job.Keys {
+		keys = append(keys, resolve(path))
+	}
+	job.Keys = keys
+	lua := make(tList, 0)
+	for _, path := range scriptFiles(job.Lua, "BAFI_LUA_PATH", defaultLuaFile, dir) {
+		lua = append(lua, resolve(path))
+	}
+	job.Lua = lua
+	js := make(tList, 0)
+	for _, path := range scriptFiles(job.JS, "BAFI_JS_PATH", defaultJSFile, dir) {
+		js = append(js, resolve(path))
+	}
+	job.JS = js
+	if job.LuaPath != "" {
+		patterns := strings.Split(job.LuaPath, ";")
+		for i, pattern := range patterns {
+			patterns[i] = resolve(pattern)
+		}
+		job.LuaPath = strings.Join(patterns, ";")
+	}
+	return job, nil
+}
+
+// params convert job to tParams with defaults of command line parameters
+func (job tJob) params() tParams {
+	params := tParams{
+		inputFile:      &job.Input,
+		inputList:      job.Inputs,
+		outputFile:     &job.Output,
+		textTemplate:   &job.Template,
+		inputFormat:    &job.Format,
+		inputDelimiter: &job.Delimiter,
+		getVersion:     new(bool),
+		getHelp:        new(bool),
+		chatGPTkey:     new(string),
+		chatGPTmodel:   new(string),
+		chatGPTquery:   new(string),
+		schemaFile:     &job.Schema,
+		outputFormat:   &job.OutputFormat,
+		outputSchema:   &job.OutputSchema,
+		strict:         &job.Strict,
+		templateDir:    &job.TemplateDir,
+		outputDir:      &job.OutputDir,
+		outputMode:     &job.OutputMode,
+		manifestFile:   &job.Manifest,
+		outputPerm:     &job.Perm,
+		outputMkdir:    &job.Mkdir,
+		onFail:         &job.OnFail,
+		vars:           tVars(job.Vars),
+		envAllow:       new(string),
+		keys:           &job.Keys,
+		luaFiles:       &job.Lua,
+		luaPath:        &job.LuaPath,
+		luaSandbox:     &job.LuaSandbox,
+		luaTimeout:     &job.LuaTimeout,
+		luaPre:         &job.LuaPre,
+		luaPost:        &job.LuaPost,
+		jsFiles:        &job.JS,
+		jsTimeout:      &job.JSTimeout,
+		jsSandbox:      &job.JSSandbox,
+		query:          &job.Query,
+		seed:           job.Seed,
+		now:            &job.Now,
+		uuidNamespace:  &job.UUIDNamespace,
+		deterministic:  &job.Deterministic,
+	}
+	*params.envAllow = strings.Join(job.EnvAllow, ",")
+	if job.OnFail == "" {
+		*params.onFail = "keep"
+	}
+	return params
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfig = `jobs:
+  single:
+    input: data.json
+    template: "?{{.name}}-{{var \"env\"}}"
+    output: out/single.txt
+    mkdir: true
+    vars:
+      env: prod
+  multi:
+    inputs:
+      - file: data.json
+        format: json
+        label: first
+      - file: data.json
+        format: json
+        label: second
+    template: "?{{.first.name}}+{{.second.name}}"
+    output: multi.txt
+    seed: 0
+  broken:
+    input: data.json
+    template: "?{{.name"
+    output: broken.txt
+`
+
+func TestReadConfig(t *testing.T) {
+	config := writeTestFile(t, "bafi.yaml", testConfig)
+	names, jobs, err := readConfig(config)
+	if err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	if strings.Join(names, ",") != "single,multi,broken" {
+		t.Errorf("result: %v", names)
+	}
+	if jobs["single"].Vars["env"] != "prod" || !jobs["single"].Mkdir || len(jobs["multi"].Inputs) != 2 {
+		t.Errorf("result: %+v", jobs)
+	}
+	params := jobs["single"].params()
+	if *params.outputPerm != "" || *params.onFail != "keep" || *params.outputFile != "out/single.txt" {
+		t.Errorf("result: %v %v %v", *params.outputPerm, *params.onFail, *params.outputFile)
+	}
+	if params.seed != nil || jobs["multi"].params().seed == nil { // seed 0 is defined seed
+		t.Errorf("result: %v %v", params.seed, jobs["multi"].params().seed)
+	}
+	invalid := writeTestFile(t, "invalid.yaml", "jobs:\n  - single\n")
+	if _, _, err := readConfig(invalid); err == nil {
+		t.Errorf("result: expected error")
+	}
+	invalid = writeTestFile(t, "inputs.yaml", "jobs:\n  single:\n    inputs:\n      - file: [data.json]\n")
+	if _, _, err := readConfig(invalid); err == nil || err.Error() != "readConfig: job single: inputList: item 1: file must be defined as string" {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestResolvePaths(t *testing.T) {
+	dir := filepath.Join("config", "dir")
+	job := tJob{
+		Input:    "data.json",
+		Inputs:   []map[string]interface{}{{"file": "a.json", "format": "json", "label": "a", "schema": "/abs/schema.json"}},
+		Template: "?{{.name}}",
+		Output:   "out.txt",
+		Lua:      tList{"lua"},
+		LuaPath:  "lib/?.lua;/usr/lib/?.lua",
+		Keys:     tList{"keys", "/etc/bafi/private.pem"},
+	}
+	resolved, err := job.resolvePaths(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Input != filepath.Join(dir, "data.json") || resolved.Template != "?{{.name}}" || resolved.Output != filepath.Join(dir, "out.txt") || resolved.Lua[0] != filepath.Join(dir, "lua") {
+		t.Errorf("result: %+v", resolved)
+	}
+	if resolved.Inputs[0]["file"] != filepath.Join(dir, "a.json") || resolved.Inputs[0]["schema"] != "/abs/schema.json" || job.Inputs[0]["file"] != "a.json" {
+		t.Errorf("result: %v %v", resolved.Inputs, job.Inputs)
+	}
+	if resolved.LuaPath != filepath.Join(dir, "lib/?.lua")+";/usr/lib/?.lua" {
+		t.Errorf("result: %v", resolved.LuaPath)
+	}
+	if resolved.Keys[0] != filepath.Join(dir, "keys") || resolved.Keys[1] != "/etc/bafi/private.pem" || job.Keys[0] != "keys" {
+		t.Errorf("result: %v %v", resolved.Keys, job.Keys)
+	}
+}
+
+func TestRunJobs(t *testing.T) {
+	config := writeTestFile(t, "bafi.yaml", testConfig)
+	dir := filepath.Dir(config)
+	if err := os.WriteFile(filepath.Join(dir, "data.json"), []byte(`{"name": "ACME"}`), 0644); err != nil {
+		t.Fatalf("writeFile: %v", err)
+	}
+	workDir, _ := os.Getwd()
+	if err := runJobs([]string{"-c", config, "single", "multi"}); err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	if current, _ := os.Getwd(); current != workDir {
+		t.Errorf("result: working directory changed to %s", current)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "out", "single.txt")); string(data) != "ACME-prod" {
+		t.Errorf("result: %v", string(data))
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "multi.txt")); string(data) != "ACME+ACME" {
+		t.Errorf("result: %v", string(data))
+	}
+	err := runJobs([]string{"-c", config, "-all"})
+	if err == nil || !strings.Contains(err.Error(), "1 job(s) failed") || !strings.Contains(err.Error(), "job broken:") {
+		t.Errorf("result: %v", err)
+	}
+	if err := runJobs([]string{"-c", config, "missing"}); err == nil || !strings.Contains(err.Error(), "unknown job missing") {
+		t.Errorf("result: %v", err)
+	}
+	if err := runJobs([]string{"-c", config}); err == nil {
+		t.Errorf("result: expected error")
+	}
+}
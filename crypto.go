@@ -0,0 +1,284 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// keyPaths key files and directories allowed by -keys (absolute paths without symlinks), key files can't be read if empty
+var keyPaths []string
+
+// prepareKeyPaths resolve key files and directories allowed by -keys to absolute paths
+func prepareKeyPaths(list []string) ([]string, error) {
+	paths := make([]string, 0, len(list))
+	for _, path := range list {
+		path, err := realPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("keys: %s", err.Error())
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// realPath absolute path with resolved symlinks
+func realPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+// readKeyFile read key file (secretKey "file:key.txt", jws) if file is allowed by -keys (file itself or file in allowed directory)
+func readKeyFile(name string, file string) ([]byte, error) {
+	path, err := realPath(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err.Error())
+	}
+	for _, allowed := range keyPaths {
+		if rel, err := filepath.Rel(allowed, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err.Error())
+			}
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: key file %s is not allowed (-keys)", name, file)
+}
+
+// hashAlgorithms hash functions by name (hmac)
+var hashAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// md5Sum MD5 checksum as hex string {{md5 .Payload}}
+func md5Sum(v string) string {
+	sum := md5.Sum([]byte(v))
+	return hex.EncodeToString(sum[:])
+}
+
+// sha1Sum SHA-1 checksum as hex string {{sha1 .Payload}}
+func sha1Sum(v string) string {
+	sum := sha1.Sum([]byte(v))
+	return hex.EncodeToString(sum[:])
+}
+
+// sha256Sum SHA-256 checksum as hex string {{sha256 .Payload}}
+func sha256Sum(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return hex.EncodeToString(sum[:])
+}
+
+// sha512Sum SHA-512 checksum as hex string {{sha512 .Payload}}
+func sha512Sum(v string) string {
+	sum := sha512.Sum512([]byte(v))
+	return hex.EncodeToString(sum[:])
+}
+
+// crc32Sum CRC-32 (IEEE) checksum as 8 characters hex string {{crc32 .Payload}}
+func crc32Sum(v string) string {
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(v)))
+}
+
+// hexEncode encode to hex {{hexenc "Hello"}} -> 48656c6c6f
+func hexEncode(v string) string {
+	return hex.EncodeToString([]byte(v))
+}
+
+// hexDecode decode from hex {{hexdec "48656c6c6f"}} -> Hello
+func hexDecode(v string) (string, error) {
+	data, err := hex.DecodeString(v)
+	if err != nil {
+		return "", fmt.Errorf("hexdec: %s", err.Error())
+	}
+	return string(data), nil
+}
+
+// secretKey get key from environment variable "env:NAME" (-envallow is applied), file "file:key.txt" allowed by -keys (trailing new line is removed) or key itself
+func secretKey(name string, key string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(key, "env:"):
+		value, err := getEnv(strings.TrimPrefix(key, "env:"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err.Error())
+		}
+		if value == "" {
+			return nil, fmt.Errorf("%s: environment variable %s is empty", name, strings.TrimPrefix(key, "env:"))
+		}
+		return []byte(value), nil
+	case strings.HasPrefix(key, "file:"):
+		data, err := readKeyFile(name, strings.TrimPrefix(key, "file:"))
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strings.TrimRight(string(data), "\r\n")), nil
+	}
+	return []byte(key), nil
+}
+
+// hmacSum HMAC signature as hex string {{hmac "sha256" "env:API_SECRET" .Payload}}
+// Algorithms: md5, sha1, sha256, sha384, sha512. Key can be "env:NAME", "file:key.txt" or key itself
+func hmacSum(algorithm string, key string, v string) (string, error) {
+	newHash, ok := hashAlgorithms[strings.ToLower(algorithm)]
+	if !ok {
+		return "", fmt.Errorf("hmac: unknown algorithm %s (accepted values are md5, sha1, sha256, sha384, sha512)", algorithm)
+	}
+	secret, err := secretKey("hmac", key)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(newHash, secret)
+	mac.Write([]byte(v))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// uuidv5 UUIDv5 from name and optional namespace (UUID, dns, url, oid, x500 or any text) {{uuidv5 .ID "invoices"}}
+func uuidv5(name string, namespace ...string) string {
+	space := ""
+	if len(namespace) > 0 {
+		space = namespace[0]
+	}
+	return uuid.NewSHA1(uuidNamespace(space), []byte(name)).String()
+}
+
+// jwsSign sign payload by key file allowed by -keys, result is JWS compact serialization {{jws "RS256" "private.pem" .Payload}}
+// Algorithms: HS256, HS384, HS512 (secret key file), RS256, RS384, RS512, PS256, PS384, PS512 (RSA key), ES256, ES384, ES512 (EC key), EdDSA (Ed25519 key)
+func jwsSign(algorithm string, keyFile string, payload string) (string, error) {
+	data, err := readKeyFile("jws", keyFile)
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(map[string]string{"alg": algorithm})
+	if err != nil {
+		return "", fmt.Errorf("jws: %s", err.Error())
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString([]byte(payload))
+	signature, err := jwsSignature(algorithm, data, []byte(input))
+	if err != nil {
+		return "", fmt.Errorf("jws: %s", err.Error())
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// jwsAlgorithms hash of JWS algorithms (RFC 7518)
+var jwsAlgorithms = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// jwsCurves EC curve required by ES algorithms
+var jwsCurves = map[string]string{"ES256": "P-256", "ES384": "P-384", "ES512": "P-521"}
+
+// jwsSignature sign input by algorithm
+func jwsSignature(algorithm string, key []byte, input []byte) ([]byte, error) {
+	if algorithm == "EdDSA" {
+		privateKey, err := parsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		edKey, ok := privateKey.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("EdDSA requires Ed25519 private key, got %T", privateKey)
+		}
+		return ed25519.Sign(edKey, input), nil
+	}
+	hashType, ok := jwsAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unknown algorithm %s (accepted values are HS256, HS384, HS512, RS256, RS384, RS512, PS256, PS384, PS512, ES256, ES384, ES512, EdDSA)", algorithm)
+	}
+	if strings.HasPrefix(algorithm, "HS") {
+		mac := hmac.New(hashType.New, []byte(strings.TrimRight(string(key), "\r\n")))
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	}
+	digest := hashType.New()
+	digest.Write(input)
+	hashed := digest.Sum(nil)
+	privateKey, err := parsePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	switch signer := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if strings.HasPrefix(algorithm, "PS") {
+			return rsa.SignPSS(rand.Reader, signer, hashType, hashed, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		if strings.HasPrefix(algorithm, "RS") {
+			return rsa.SignPKCS1v15(rand.Reader, signer, hashType, hashed)
+		}
+	case *ecdsa.PrivateKey:
+		if strings.HasPrefix(algorithm, "ES") {
+			if curve := signer.Curve.Params().Name; curve != jwsCurves[algorithm] {
+				return nil, fmt.Errorf("%s requires %s private key, got %s", algorithm, jwsCurves[algorithm], curve)
+			}
+			r, s, err := ecdsa.Sign(rand.Reader, signer, hashed)
+			if err != nil {
+				return nil, err
+			}
+			// Signature is R || S, both padded to curve size
+			size := (signer.Curve.Params().BitSize + 7) / 8
+			signature := make([]byte, 2*size)
+			r.FillBytes(signature[:size])
+			s.FillBytes(signature[size:])
+			return signature, nil
+		}
+	}
+	return nil, fmt.Errorf("%s can't be used with %T private key", algorithm, privateKey)
+}
+
+// parsePrivateKey parse PEM private key (PKCS#8, PKCS#1 RSA or SEC 1 EC)
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("private key must be PEM encoded")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key %T", key)
+	}
+	return signer, nil
+}
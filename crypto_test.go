@@ -0,0 +1,247 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHashes(t *testing.T) {
+	if result := md5Sum("abc"); result != "900150983cd24fb0d6963f7d28e17f72" {
+		t.Errorf("result: %s", result)
+	}
+	if result := sha1Sum("abc"); result != "a9993e364706816aba3e25717850c26c9cd0d89d" {
+		t.Errorf("result: %s", result)
+	}
+	if result := sha256Sum("abc"); result != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("result: %s", result)
+	}
+	if result := sha512Sum("abc"); result != "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f" {
+		t.Errorf("result: %s", result)
+	}
+	if result := crc32Sum("The quick brown fox jumps over the lazy dog"); result != "414fa339" {
+		t.Errorf("result: %s", result)
+	}
+	if err := runt(`{{"abc" | sha256 | hexdec | b64enc}}`, "ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0="); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHex(t *testing.T) {
+	if result := hexEncode("Hello"); result != "48656c6c6f" {
+		t.Errorf("result: %s", result)
+	}
+	if result, err := hexDecode("48656C6C6F"); err != nil || result != "Hello" {
+		t.Errorf("result: %s %v", result, err)
+	}
+	if _, err := hexDecode("xyz"); err == nil || !strings.HasPrefix(err.Error(), "hexdec: encoding/hex: invalid byte") {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestHmac(t *testing.T) {
+	// RFC 4231 test case 2
+	expected := "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if result, err := hmacSum("sha256", "Jefe", "what do ya want for nothing?"); err != nil || result != expected {
+		t.Errorf("result: %s %v", result, err)
+	}
+	t.Setenv("BAFI_TEST_SECRET", "Jefe")
+	if result, err := hmacSum("SHA256", "env:BAFI_TEST_SECRET", "what do ya want for nothing?"); err != nil || result != expected {
+		t.Errorf("result: %s %v", result, err)
+	}
+	keyFile := writeTestFile(t, "secret.txt", "Jefe\n")
+	if _, err := hmacSum("sha256", "file:"+keyFile, "data"); err == nil || err.Error() != "hmac: key file "+keyFile+" is not allowed (-keys)" {
+		t.Errorf("result: %v", err)
+	}
+	allowKeys(t, filepath.Dir(keyFile))
+	if result, err := hmacSum("sha256", "file:"+keyFile, "what do ya want for nothing?"); err != nil || result != expected {
+		t.Errorf("result: %s %v", result, err)
+	}
+	// Files outside of allowed directory (also by relative path or symlink) are not readable
+	outside := writeTestFile(t, "passwd", "secret")
+	if _, err := hmacSum("sha256", "file:"+filepath.Join(filepath.Dir(keyFile), "..", filepath.Base(filepath.Dir(outside)), "passwd"), "data"); err == nil || !strings.HasSuffix(err.Error(), "is not allowed (-keys)") {
+		t.Errorf("result: %v", err)
+	}
+	link := filepath.Join(filepath.Dir(keyFile), "link.txt")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hmacSum("sha256", "file:"+link, "data"); err == nil || err.Error() != "hmac: key file "+link+" is not allowed (-keys)" {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := hmacSum("sha256", "env:BAFI_TEST_MISSING", "data"); err == nil || err.Error() != "hmac: environment variable BAFI_TEST_MISSING is empty" {
+		t.Errorf("result: %v", err)
+	}
+	envAllow = []string{"TENANT"}
+	defer func() { envAllow = nil }()
+	if _, err := hmacSum("sha256", "env:BAFI_TEST_SECRET", "data"); err == nil || err.Error() != "hmac: env: variable BAFI_TEST_SECRET is not allowed (-envallow)" {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := hmacSum("sha3", "key", "data"); err == nil || !strings.HasPrefix(err.Error(), "hmac: unknown algorithm sha3") {
+		t.Errorf("result: %v", err)
+	}
+}
+
+// allowKeys allow key files and directories for the test (-keys)
+func allowKeys(t *testing.T, paths ...string) {
+	t.Helper()
+	allowed, err := prepareKeyPaths(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPaths = allowed
+	t.Cleanup(func() { keyPaths = nil })
+}
+
+func TestPrepareKeyPaths(t *testing.T) {
+	if _, err := prepareKeyPaths([]string{filepath.Join(t.TempDir(), "missing")}); err == nil || !strings.HasPrefix(err.Error(), "keys: lstat ") {
+		t.Errorf("result: %v", err)
+	}
+	if paths, err := prepareKeyPaths(nil); err != nil || len(paths) != 0 {
+		t.Errorf("result: %v %v", paths, err)
+	}
+}
+
+func TestUUIDv5(t *testing.T) {
+	if result := uuidv5("python.org", "dns"); result != "886313e1-3b8a-5372-9b90-0c9aee199e5d" {
+		t.Errorf("result: %s", result)
+	}
+	if result := uuidv5("python.org", "6ba7b810-9dad-11d1-80b4-00c04fd430c8"); result != "886313e1-3b8a-5372-9b90-0c9aee199e5d" {
+		t.Errorf("result: %s", result)
+	}
+	if uuidv5("1") != uuidv5("1") || uuidv5("1") == uuidv5("2") || uuidv5("1") == uuidv5("1", "invoices") {
+		t.Error("uuidv5: unexpected result")
+	}
+}
+
+// pemKey encode private key to PKCS#8 PEM
+func pemKey(t *testing.T, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// jwsParts split JWS compact serialization to signing input and decoded signature
+func jwsParts(t *testing.T, token string) ([]byte, []byte) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("invalid token: %s", token)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []byte(parts[0] + "." + parts[1]), signature
+}
+
+func TestJWS(t *testing.T) {
+	payload := `{"amount":"1234.56"}`
+	// HS256 with secret key file
+	secretFile := writeTestFile(t, "secret.txt", "secret\n")
+	if _, err := jwsSign("HS256", secretFile, payload); err == nil || err.Error() != "jws: key file "+secretFile+" is not allowed (-keys)" {
+		t.Errorf("result: %v", err)
+	}
+	allowKeys(t, secretFile)
+	token, err := jwsSign("HS256", secretFile, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, "eyJhbGciOiJIUzI1NiJ9.eyJhbW91bnQiOiIxMjM0LjU2In0.") {
+		t.Errorf("result: %s", token)
+	}
+	input, signature := jwsParts(t, token)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(input)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		t.Error("HS256: invalid signature")
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaFile := writeTestFile(t, "rsa.pem", string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})))
+	allowKeys(t, secretFile, rsaFile)
+	token, err = jwsSign("RS256", rsaFile, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input, signature = jwsParts(t, token)
+	digest := sha256.Sum256(input)
+	if err := rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("RS256: %v", err)
+	}
+	token, err = jwsSign("PS256", rsaFile, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input, signature = jwsParts(t, token)
+	digest = sha256.Sum256(input)
+	if err := rsa.VerifyPSS(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature, nil); err != nil {
+		t.Errorf("PS256: %v", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecFile := writeTestFile(t, "ec.pem", pemKey(t, ecKey))
+	allowKeys(t, secretFile, rsaFile, ecFile)
+	token, err = jwsSign("ES256", ecFile, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input, signature = jwsParts(t, token)
+	digest = sha256.Sum256(input)
+	if len(signature) != 64 || !ecdsa.Verify(&ecKey.PublicKey, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		t.Error("ES256: invalid signature")
+	}
+	if _, err := jwsSign("ES384", ecFile, payload); err == nil || err.Error() != "jws: ES384 requires P-384 private key, got P-256" {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := jwsSign("RS256", ecFile, payload); err == nil || err.Error() != "jws: RS256 can't be used with *ecdsa.PrivateKey private key" {
+		t.Errorf("result: %v", err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edFile := writeTestFile(t, "ed.pem", pemKey(t, edKey))
+	allowKeys(t, secretFile, rsaFile, ecFile, edFile)
+	token, err = jwsSign("EdDSA", edFile, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input, signature = jwsParts(t, token)
+	if !ed25519.Verify(edKey.Public().(ed25519.PublicKey), input, signature) {
+		t.Error("EdDSA: invalid signature")
+	}
+
+	if _, err := jwsSign("none", secretFile, payload); err == nil || !strings.HasPrefix(err.Error(), "jws: unknown algorithm none") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := jwsSign("RS256", secretFile, payload); err == nil || err.Error() != "jws: private key must be PEM encoded" {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := jwsSign("HS256", "missing.key", payload); err == nil || !strings.HasPrefix(err.Error(), "jws: lstat ") {
+		t.Errorf("result: %v", err)
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// isoLayouts ISO-8601 layouts (extended and basic format) used by parseDate if no layout is defined
+var isoLayouts = []string{
+	time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02",
+	"20060102T150405Z0700", "20060102T150405", "20060102",
+}
+
+// strftimeDirectives strftime directive to Go layout
+var strftimeDirectives = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2", 'j': "002",
+	'H': "15", 'I': "03", 'M': "04", 'S': "05", 'p': "PM", 'f': "000000",
+	'b': "Jan", 'B': "January", 'a': "Mon", 'A': "Monday",
+	'z': "-0700", 'Z': "MST", 'F': "2006-01-02", 'T': "15:04:05", '%': "%",
+}
+
+// dateUnits calendar units accepted by dateAdd, dateDiff, startOf, endOf (plural and short forms are normalized).
+// Ambiguous "m" (month or minute) is not accepted, short forms are "mo" and "min"
+var dateUnits = map[string]string{
+	"y": "year", "year": "year", "years": "year",
+	"q": "quarter", "quarter": "quarter", "quarters": "quarter",
+	"mo": "month", "month": "month", "months": "month",
+	"w": "week", "week": "week", "weeks": "week",
+	"d": "day", "day": "day", "days": "day",
+	"h": "hour", "hour": "hour", "hours": "hour",
+	"min": "minute", "minute": "minute", "minutes": "minute",
+	"s": "second", "second": "second", "seconds": "second",
+}
+
+// dateLayout convert layout to Go layout. Layout can be Go layout, strftime format (contains %) or iso8601/rfc3339
+func dateLayout(layout string) ([]string, error) {
+	switch strings.ToLower(layout) {
+	case "iso8601", "iso-8601":
+		return isoLayouts, nil
+	case "rfc3339":
+		return []string{time.RFC3339Nano}, nil
+	}
+	if !strings.Contains(layout, "%") {
+		return []string{layout}, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' {
+			b.WriteByte(layout[i])
+			continue
+		}
+		if i+1 == len(layout) {
+			return nil, fmt.Errorf("incomplete strftime directive in %s", layout)
+		}
+		i++
+		directive, ok := strftimeDirectives[layout[i]]
+		if !ok {
+			return nil, fmt.Errorf("unknown strftime directive %%%c", layout[i])
+		}
+		b.WriteString(directive)
+	}
+	return []string{b.String()}, nil
+}
+
+// toTime convert value to time. Accepts time, unix timestamp (number) and string in ISO-8601 format
+func toTime(name string, v interface{}) (time.Time, error) {
+	switch value := v.(type) {
+	case time.Time:
+		return value, nil
+	case string:
+		for _, layout := range isoLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("%s: cannot parse date %s (use parseDate for other formats)", name, value)
+	}
+	if d, ok := numericValue(v); ok {
+		return time.Unix(d.IntPart(), 0).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("%s: cannot convert %v to date", name, v)
+}
+
+// parseDate parse date by first matching layout {{parseDate .Value "02.01.2006" "%Y/%m/%d"}}, default is ISO-8601
+// Layouts can be Go layout, strftime format or iso8601/rfc3339. Result is time which can be used in other date functions
+func parseDate(value string, layouts ...string) (time.Time, error) {
+	if len(layouts) == 0 {
+		layouts = []string{"iso8601"}
+	}
+	for _, layout := range layouts {
+		goLayouts, err := dateLayout(layout)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parseDate: %s", err.Error())
+		}
+		for _, goLayout := range goLayouts {
+			if t, err := time.Parse(goLayout, strings.TrimSpace(value)); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("parseDate: cannot parse %s by layouts %s", value, strings.Join(layouts, ", "))
+}
+
+// formatDate format date by Go layout or strftime format {{parseDate .Value "02.01.2006" | formatDate "%Y-%m-%d"}}
+func formatDate(layout string, date interface{}) (string, error) {
+	t, err := toTime("formatDate", date)
+	if err != nil {
+		return "", err
+	}
+	goLayouts, err := dateLayout(layout)
+	if err != nil {
+		return "", fmt.Errorf("formatDate: %s", err.Error())
+	}
+	return t.Format(goLayouts[0]), nil
+}
+
+// nowTZ return current date/time in specified format and timezone {{nowTZ "2006-01-02 15:04" "Europe/Prague"}}
+func nowTZ(format string, timeZone string) (string, error) {
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return "", fmt.Errorf("nowTZ: %s", err.Error())
+	}
+	return clock().In(location).Format(format), nil
+}
+
+// toTimezone convert date to timezone {{parseDate .Value | toTimezone "Europe/Prague" | formatDate "15:04"}}
+func toTimezone(timeZone string, date interface{}) (time.Time, error) {
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("toTimezone: %s", err.Error())
+	}
+	t, err := toTime("toTimezone", date)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(location), nil
+}
+
+// addMonths add months, day is limited to last day of target month (Jan 31 + 1 month = Feb 28)
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location()).AddDate(0, months, 0)
+	day := first.AddDate(0, 1, -1).Day()
+	if t.Day() < day {
+		day = t.Day()
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// dateAdd add duration {{dateAdd "1h30m" .Date}} or calendar units {{dateAdd "1 month -2 days" .Date}}
+func dateAdd(amount string, date interface{}) (time.Time, error) {
+	t, err := toTime("dateAdd", date)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if duration, err := time.ParseDuration(strings.ReplaceAll(amount, " ", "")); err == nil {
+		return t.Add(duration), nil
+	}
+	fields := strings.Fields(amount)
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return time.Time{}, fmt.Errorf("dateAdd: invalid amount %s (e.g. 1h30m or 1 month 2 days)", amount)
+	}
+	for i := 0; i < len(fields); i += 2 {
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("dateAdd: invalid amount %s (e.g. 1h30m or 1 month 2 days)", amount)
+		}
+		switch dateUnits[strings.ToLower(fields[i+1])] {
+		case "year":
+			t = addMonths(t, 12*n)
+		case "quarter":
+			t = addMonths(t, 3*n)
+		case "month":
+			t = addMonths(t, n)
+		case "week":
+			t = t.AddDate(0, 0, 7*n)
+		case "day":
+			t = t.AddDate(0, 0, n)
+		case "hour":
+			t = t.Add(time.Duration(n) * time.Hour)
+		case "minute":
+			t = t.Add(time.Duration(n) * time.Minute)
+		case "second":
+			t = t.Add(time.Duration(n) * time.Second)
+		default:
+			return time.Time{}, fmt.Errorf("dateAdd: unknown unit %s", fields[i+1])
+		}
+	}
+	return t, nil
+}
+
+// daysBetween count of calendar days between dates, dates (in their own time zone) are compared at midnight, so day with DST change counts as one day
+func daysBetween(from, to time.Time) int {
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDay.Sub(fromDay) / (24 * time.Hour))
+}
+
+// monthsBetween count of whole months between dates
+func monthsBetween(from, to time.Time) int {
+	if to.Before(from) {
+		return -monthsBetween(to, from)
+	}
+	months := (to.Year()-from.Year())*12 + int(to.Month()-from.Month())
+	if months > 0 && addMonths(from, months).After(to) {
+		months--
+	}
+	return months
+}
+
+// dateDiff difference between dates (to - from) in whole units {{dateDiff "days" .From .To}}
+// Units: years, quarters, months, weeks, days (calendar, not affected by DST), hours, minutes, seconds, duration (e.g. 36h0m0s)
+func dateDiff(unit string, from, to interface{}) (interface{}, error) {
+	t1, err := toTime("dateDiff", from)
+	if err != nil {
+		return nil, err
+	}
+	t2, err := toTime("dateDiff", to)
+	if err != nil {
+		return nil, err
+	}
+	duration := t2.Sub(t1)
+	switch dateUnits[strings.ToLower(unit)] {
+	case "year":
+		return monthsBetween(t1, t2) / 12, nil
+	case "quarter":
+		return monthsBetween(t1, t2) / 3, nil
+	case "month":
+		return monthsBetween(t1, t2), nil
+	case "week":
+		return daysBetween(t1, t2) / 7, nil
+	case "day":
+		return daysBetween(t1, t2), nil
+	case "hour":
+		return int(duration / time.Hour), nil
+	case "minute":
+		return int(duration / time.Minute), nil
+	case "second":
+		return int(duration / time.Second), nil
+	}
+	if strings.ToLower(unit) == "duration" {
+		return duration.String(), nil
+	}
+	return nil, fmt.Errorf("dateDiff: unknown unit %s", unit)
+}
+
+// startOf beginning of day, week (monday), month, quarter, year {{startOf "month" .Date}}
+func startOf(unit string, date interface{}) (time.Time, error) {
+	t, err := toTime("startOf", date)
+	if err != nil {
+		return time.Time{}, err
+	}
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch dateUnits[strings.ToLower(unit)] {
+	case "day":
+		return day, nil
+	case "week":
+		return day.AddDate(0, 0, -(int(t.Weekday())+6)%7), nil
+	case "month":
+		return day.AddDate(0, 0, 1-t.Day()), nil
+	case "quarter":
+		return time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, t.Location()), nil
+	case "year":
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location()), nil
+	}
+	return time.Time{}, fmt.Errorf("startOf: unknown unit %s (accepted values are day, week, month, quarter, year)", unit)
+}
+
+// endOf last nanosecond of day, week (sunday), month, quarter, year {{endOf "quarter" .Date | formatDate "2006-01-02"}}
+func endOf(unit string, date interface{}) (time.Time, error) {
+	start, err := startOf(unit, date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("endOf: %s", strings.TrimPrefix(err.Error(), "startOf: "))
+	}
+	var next time.Time
+	switch dateUnits[strings.ToLower(unit)] {
+	case "day":
+		next = start.AddDate(0, 0, 1)
+	case "week":
+		next = start.AddDate(0, 0, 7)
+	case "month":
+		next = start.AddDate(0, 1, 0)
+	case "quarter":
+		next = start.AddDate(0, 3, 0)
+	case "year":
+		next = start.AddDate(1, 0, 0)
+	}
+	return next.Add(-time.Nanosecond), nil
+}
+
+// weekday ISO day of week, 1 = monday ... 7 = sunday {{weekday .Date}}
+func weekday(date interface{}) (int, error) {
+	t, err := toTime("weekday", date)
+	if err != nil {
+		return 0, err
+	}
+	return (int(t.Weekday())+6)%7 + 1, nil
+}
+
+// isoWeek ISO-8601 week number {{isoWeek .Date}}
+func isoWeek(date interface{}) (int, error) {
+	t, err := toTime("isoWeek", date)
+	if err != nil {
+		return 0, err
+	}
+	_, week := t.ISOWeek()
+	return week, nil
+}
+
+// holidaySet dates of holidays (list of dates or single date) as set of "2006-01-02"
+func holidaySet(name string, holidays []interface{}) (map[string]bool, error) {
+	set := make(map[string]bool)
+	for _, list := range holidays {
+		for _, holiday := range toList(list) {
+			t, err := toTime(name, holiday)
+			if err != nil {
+				return nil, err
+			}
+			set[t.Format("2006-01-02")] = true
+		}
+	}
+	return set, nil
+}
+
+// businessDay check if date is not weekend or holiday
+func businessDay(t time.Time, holidays map[string]bool) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday && !holidays[t.Format("2006-01-02")]
+}
+
+// dateAndHolidays split arguments to optional holidays and date (last argument)
+func dateAndHolidays(name string, args []interface{}) (time.Time, map[string]bool, error) {
+	if len(args) == 0 {
+		return time.Time{}, nil, fmt.Errorf("%s: date must be defined", name)
+	}
+	t, err := toTime(name, args[len(args)-1])
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	holidays, err := holidaySet(name, args[:len(args)-1])
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	return t, holidays, nil
+}
+
+// isBusinessDay check if date is business day (monday - friday and not holiday) {{isBusinessDay .Holidays .Date}}
+func isBusinessDay(args ...interface{}) (bool, error) {
+	t, holidays, err := dateAndHolidays("isBusinessDay", args)
+	if err != nil {
+		return false, err
+	}
+	return businessDay(t, holidays), nil
+}
+
+// addBusinessDays add business days (weekends and holidays are skipped) {{addBusinessDays 10 .Holidays .Date}}
+func addBusinessDays(days int, args ...interface{}) (time.Time, error) {
+	t, holidays, err := dateAndHolidays("addBusinessDays", args)
+	if err != nil {
+		return time.Time{}, err
+	}
+	step, remaining := 1, days
+	if days < 0 {
+		step, remaining = -1, -days
+	}
+	for remaining > 0 {
+		t = t.AddDate(0, 0, step)
+		if businessDay(t, holidays) {
+			remaining--
+		}
+	}
+	return t, nil
+}
+
+// businessDays count business days from date (inclusive) to date (exclusive) {{businessDays .Holidays .From .To}}
+// Holidays are optional and come first as in isBusinessDay and addBusinessDays
+func businessDays(args ...interface{}) (int, error) {
+	if len(args) < 2 {
+		return 0, fmt.Errorf("businessDays: dates from and to must be defined")
+	}
+	t1, err := toTime("businessDays", args[len(args)-2])
+	if err != nil {
+		return 0, err
+	}
+	t2, err := toTime("businessDays", args[len(args)-1])
+	if err != nil {
+		return 0, err
+	}
+	set, err := holidaySet("businessDays", args[:len(args)-2])
+	if err != nil {
+		return 0, err
+	}
+	t1 = time.Date(t1.Year(), t1.Month(), t1.Day(), 0, 0, 0, 0, t1.Location())
+	t2 = time.Date(t2.Year(), t2.Month(), t2.Day(), 0, 0, 0, 0, t2.Location())
+	sign := 1
+	if t2.Before(t1) {
+		t1, t2, sign = t2, t1, -1
+	}
+	count := 0
+	for day := t1; day.Before(t2); day = day.AddDate(0, 0, 1) {
+		if businessDay(day, set) {
+			count++
+		}
+	}
+	return sign * count, nil
+}
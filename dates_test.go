@@ -0,0 +1,205 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value    string
+		layouts  []string
+		expected string
+	}{
+		{"2021-08-26T22:14:00Z", nil, "2021-08-26T22:14:00Z"},
+		{"2021-08-26", nil, "2021-08-26T00:00:00Z"},
+		{"20210826T221400+0200", nil, "2021-08-26T22:14:00+02:00"},
+		{"26.08.2021", []string{"2006-01-02", "02.01.2006"}, "2021-08-26T00:00:00Z"},
+		{"2021/08/26 22:14", []string{"%Y/%m/%d %H:%M"}, "2021-08-26T22:14:00Z"},
+		{"26 Aug 2021", []string{"%d %b %Y"}, "2021-08-26T00:00:00Z"},
+		{"2021-08-26T22:14:00.5+01:00", []string{"rfc3339"}, "2021-08-26T22:14:00.5+01:00"},
+	}
+	for _, test := range tests {
+		result, err := parseDate(test.value, test.layouts...)
+		if err != nil || result.Format(time.RFC3339Nano) != test.expected {
+			t.Errorf("parseDate %s %v: %v %v", test.value, test.layouts, result, err)
+		}
+	}
+	if _, err := parseDate("26.08.2021", "2006-01-02", "%Y"); err == nil || err.Error() != "parseDate: cannot parse 26.08.2021 by layouts 2006-01-02, %Y" {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := parseDate("2021", "%Q"); err == nil || err.Error() != "parseDate: unknown strftime directive %Q" {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	if err := runt(`{{parseDate "26.08.2021 22:14" "%d.%m.%Y %H:%M" | formatDate "%Y-%m-%dT%H:%M:%S"}}`, "2021-08-26T22:14:00"); err != nil {
+		t.Error(err)
+	}
+	if result, err := formatDate("02.01.2006", "2021-08-26"); err != nil || result != "26.08.2021" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := formatDate("2006-01-02", 1615766400); err != nil || result != "2021-03-15" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := formatDate("2006", "26.08.2021"); err == nil || !strings.Contains(err.Error(), "formatDate: cannot parse date 26.08.2021") {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	if result, err := nowTZ("2006-01-02", "UTC"); err != nil || result != time.Now().UTC().Format("2006-01-02") {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := nowTZ("2006", "42"); err == nil || err.Error() != "nowTZ: unknown time zone 42" {
+		t.Errorf("result: %v", err)
+	}
+	if err := runt(`{{parseDate "2021-08-26T03:35:00+04:00" | toTimezone "Europe/Prague" | formatDate "15:04"}}`, "01:35"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDateAdd(t *testing.T) {
+	tests := []struct {
+		amount   string
+		date     string
+		expected string
+	}{
+		{"1h30m", "2021-08-26T22:00:00Z", "2021-08-26T23:30:00Z"},
+		{"-2 days", "2021-03-01", "2021-02-27T00:00:00Z"},
+		{"1 month", "2021-01-31", "2021-02-28T00:00:00Z"},
+		{"1 month", "2024-01-31", "2024-02-29T00:00:00Z"},
+		{"1 year 2 weeks", "2020-02-29", "2021-03-14T00:00:00Z"},
+		{"-1 quarter", "2021-05-31", "2021-02-28T00:00:00Z"},
+		{"90 minutes", "2021-08-26T22:00:00Z", "2021-08-26T23:30:00Z"},
+	}
+	for _, test := range tests {
+		result, err := dateAdd(test.amount, test.date)
+		if err != nil || result.Format(time.RFC3339) != test.expected {
+			t.Errorf("dateAdd %s %s: %v %v", test.amount, test.date, result, err)
+		}
+	}
+	for _, amount := range []string{"", "1 month 2", "x days", "1 fortnight"} {
+		if _, err := dateAdd(amount, "2021-01-01"); err == nil || !strings.HasPrefix(err.Error(), "dateAdd: ") {
+			t.Errorf("dateAdd %s: %v", amount, err)
+		}
+	}
+}
+
+func TestDateDiff(t *testing.T) {
+	tests := []struct {
+		unit     string
+		from, to string
+		expected interface{}
+	}{
+		{"days", "2021-01-01", "2021-03-01", 59},
+		{"months", "2021-01-31", "2021-02-28", 1},
+		{"months", "2021-01-15", "2021-03-14", 1},
+		{"months", "2021-03-14", "2021-01-15", -1},
+		{"years", "2020-02-29", "2021-02-28", 1},
+		{"quarters", "2021-01-01", "2021-12-31", 3},
+		{"weeks", "2021-01-01", "2021-01-15", 2},
+		{"weeks", "2021-01-15", "2021-01-01", -2},
+		// DST change (Europe/Prague 2021-03-28) doesn't shorten day
+		{"days", "2021-03-27T12:00:00+01:00", "2021-03-28T12:00:00+02:00", 1},
+		{"weeks", "2021-03-22T00:00:00+01:00", "2021-03-29T00:00:00+02:00", 1},
+		{"d", "2021-01-01T23:00:00Z", "2021-01-02T01:00:00Z", 1},
+		{"mo", "2021-01-01", "2021-03-01", 2},
+		{"min", "2021-01-01T00:00:00Z", "2021-01-01T01:30:00Z", 90},
+		{"hours", "2021-01-01T00:00:00Z", "2021-01-02T12:30:00Z", 36},
+		{"duration", "2021-01-01T00:00:00Z", "2021-01-02T12:30:00Z", "36h30m0s"},
+	}
+	for _, test := range tests {
+		if result, err := dateDiff(test.unit, test.from, test.to); err != nil || result != test.expected {
+			t.Errorf("dateDiff %s %s %s: %v %v", test.unit, test.from, test.to, result, err)
+		}
+	}
+	if _, err := dateDiff("eons", "2021-01-01", "2021-01-02"); err == nil || err.Error() != "dateDiff: unknown unit eons" {
+		t.Errorf("result: %v", err)
+	}
+	// Ambiguous unit m (month or minute) is rejected
+	if _, err := dateDiff("m", "2021-01-01", "2021-01-02"); err == nil || err.Error() != "dateDiff: unknown unit m" {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestStartEndOf(t *testing.T) {
+	tests := []struct {
+		unit       string
+		start, end string
+	}{
+		{"day", "2021-08-26T00:00:00Z", "2021-08-26T23:59:59.999999999Z"},
+		{"week", "2021-08-23T00:00:00Z", "2021-08-29T23:59:59.999999999Z"},
+		{"month", "2021-08-01T00:00:00Z", "2021-08-31T23:59:59.999999999Z"},
+		{"quarter", "2021-07-01T00:00:00Z", "2021-09-30T23:59:59.999999999Z"},
+		{"year", "2021-01-01T00:00:00Z", "2021-12-31T23:59:59.999999999Z"},
+	}
+	for _, test := range tests {
+		start, err := startOf(test.unit, "2021-08-26T22:14:00Z")
+		if err != nil || start.Format(time.RFC3339Nano) != test.start {
+			t.Errorf("startOf %s: %v %v", test.unit, start, err)
+		}
+		end, err := endOf(test.unit, "2021-08-26T22:14:00Z")
+		if err != nil || end.Format(time.RFC3339Nano) != test.end {
+			t.Errorf("endOf %s: %v %v", test.unit, end, err)
+		}
+	}
+	if _, err := endOf("hour", "2021-08-26"); err == nil || !strings.HasPrefix(err.Error(), "endOf: unknown unit hour") {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestWeekday(t *testing.T) {
+	if result, err := weekday("2021-08-29"); err != nil || result != 7 {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := weekday("2021-08-23"); err != nil || result != 1 {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := isoWeek("2021-01-03"); err != nil || result != 53 {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := isoWeek("2021-08-26"); err != nil || result != 34 {
+		t.Errorf("result: %v %v", result, err)
+	}
+}
+
+func TestBusinessDays(t *testing.T) {
+	holidays := []interface{}{"2021-12-24", "2021-12-27"}
+	if result, err := isBusinessDay(holidays, "2021-12-24"); err != nil || result {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := isBusinessDay("2021-12-24"); err != nil || !result {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := isBusinessDay("2021-12-25"); err != nil || result {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := addBusinessDays(2, holidays, "2021-12-23"); err != nil || result.Format("2006-01-02") != "2021-12-29" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := addBusinessDays(-2, "2021-12-27"); err != nil || result.Format("2006-01-02") != "2021-12-23" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := businessDays(holidays, "2021-12-01", "2022-01-01"); err != nil || result != 21 {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := businessDays("2022-01-01", "2021-12-01"); err != nil || result != -23 {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := addBusinessDays(1); err == nil || err.Error() != "addBusinessDays: date must be defined" {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := businessDays("2021-12-01"); err == nil || err.Error() != "businessDays: dates from and to must be defined" {
+		t.Errorf("result: %v", err)
+	}
+	// Holidays come first in all business day functions
+	if err := runtv(`{{businessDays .holidays "2021-12-23" "2021-12-29"}} {{isBusinessDay .holidays "2021-12-27"}}`, "2 false", map[string]interface{}{"holidays": holidays}); err != nil {
+		t.Error(err)
+	}
+	if err := runtv(`{{addBusinessDays 1 .holidays "2021-12-23" | formatDate "2006-01-02"}}`, "2021-12-28", map[string]interface{}{"holidays": holidays}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// defaultDivScale scale of decDiv result if not defined
+const defaultDivScale = 16
+
+// decValue convert value (string, int, float, decimal) to decimal
+func decValue(name string, v interface{}) (decimal.Decimal, error) {
+	value, ok := numericValue(v)
+	if !ok {
+		return decimal.Zero, fmt.Errorf("%s: value %v is not a number", name, v)
+	}
+	return value, nil
+}
+
+// decOperation apply operation on first value and all following values
+func decOperation(name string, a interface{}, b []interface{}, f func(d1, d2 decimal.Decimal) decimal.Decimal) (decimal.Decimal, error) {
+	result, err := decValue(name, a)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	for _, x := range b {
+		value, err := decValue(name, x)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		result = f(result, value)
+	}
+	return result, nil
+}
+
+// decAdd decimal addition {{decAdd "0.1" 0.2 .Amount}}
+func decAdd(a interface{}, b ...interface{}) (decimal.Decimal, error) {
+	return decOperation("decAdd", a, b, decimal.Decimal.Add)
+}
+
+// decSub decimal subtraction {{decSub .Total .Discount}}
+func decSub(a interface{}, b ...interface{}) (decimal.Decimal, error) {
+	return decOperation("decSub", a, b, decimal.Decimal.Sub)
+}
+
+// decMul decimal multiplication {{decMul .Price .Quantity}}
+func decMul(a interface{}, b ...interface{}) (decimal.Decimal, error) {
+	return decOperation("decMul", a, b, decimal.Decimal.Mul)
+}
+
+// decDiv decimal division with optional scale (default 16) and rounding mode (default half-up) {{decDiv .Total 3 2 "half-even"}}
+func decDiv(a, b interface{}, options ...interface{}) (decimal.Decimal, error) {
+	dividend, err := decValue("decDiv", a)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	divisor, err := decValue("decDiv", b)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if divisor.IsZero() {
+		return decimal.Zero, fmt.Errorf("decDiv: division by zero")
+	}
+	scale, mode, err := roundOptions("decDiv", defaultDivScale, options)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	// Truncated quotient with 2 extra digits and sticky digit for non-zero remainder, so rounding mode is applied to exact value
+	precision := scale + 2
+	quotient, remainder := dividend.QuoRem(divisor, precision)
+	if !remainder.IsZero() {
+		quotient = quotient.Add(decimal.New(int64(dividend.Sign()*divisor.Sign()), -(precision + 1)))
+	}
+	result, err := roundDecimal(quotient, scale, mode)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("decDiv: %s", err.Error())
+	}
+	return result, nil
+}
+
+// decRound round decimal to scale with optional rounding mode (default half-up) {{decRound .Amount 2 "half-even"}}
+func decRound(value interface{}, scale int, mode ...string) (decimal.Decimal, error) {
+	d, err := decValue("decRound", value)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	roundMode := "half-up"
+	if len(mode) > 0 {
+		roundMode = mode[0]
+	}
+	result, err := roundDecimal(d, int32(scale), roundMode)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("decRound: %s", err.Error())
+	}
+	return result, nil
+}
+
+// decCmp compare decimals, returns -1 if a < b, 0 if a == b, 1 if a > b {{if eq (decCmp .Total 1000) 1}}
+func decCmp(a, b interface{}) (int, error) {
+	d1, err := decValue("decCmp", a)
+	if err != nil {
+		return 0, err
+	}
+	d2, err := decValue("decCmp", b)
+	if err != nil {
+		return 0, err
+	}
+	return d1.Cmp(d2), nil
+}
+
+// decFormat format decimal with fixed scale (half-up) and optional thousands and decimal separator {{decFormat 1234.5 2 " " ","}} -> 1 234,50
+func decFormat(value interface{}, scale int, separators ...string) (string, error) {
+	d, err := decValue("decFormat", value)
+	if err != nil {
+		return "", err
+	}
+	thousands, decimalSeparator := ",", "."
+	if len(separators) > 0 {
+		thousands = separators[0]
+	}
+	if len(separators) > 1 {
+		decimalSeparator = separators[1]
+	}
+	return formatDecimal(d.Round(int32(scale)), int32(scale), thousands, decimalSeparator, 3), nil
+}
+
+// formatDecimal format decimal with fixed scale, digits of integer part are grouped by group size
+func formatDecimal(d decimal.Decimal, scale int32, thousands, decimalSeparator string, group int) string {
+	str := d.Abs().StringFixed(scale)
+	integer, fraction, _ := strings.Cut(str, ".")
+	var b strings.Builder
+	if d.Sign() < 0 {
+		b.WriteString("-")
+	}
+	for i, digit := range integer {
+		if i > 0 && group > 0 && (len(integer)-i)%group == 0 {
+			b.WriteString(thousands)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(decimalSeparator)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
+
+// roundOptions parse optional scale and rounding mode
+func roundOptions(name string, defaultScale int32, options []interface{}) (int32, string, error) {
+	scale, mode := defaultScale, "half-up"
+	if len(options) > 0 {
+		value, ok := numericValue(options[0])
+		if !ok || !value.IsInteger() {
+			return 0, "", fmt.Errorf("%s: scale must be integer, got %v", name, options[0])
+		}
+		scale = int32(value.IntPart())
+	}
+	if len(options) > 1 {
+		mode = toString(options[1])
+	}
+	if len(options) > 2 {
+		return 0, "", fmt.Errorf("%s: too many arguments", name)
+	}
+	return scale, mode, nil
+}
+
+// roundDecimal round decimal to scale by rounding mode: half-up (away from zero), half-even (banker's), up (away from zero), down (towards zero), ceil, floor
+func roundDecimal(d decimal.Decimal, scale int32, mode string) (decimal.Decimal, error) {
+	switch strings.ToLower(mode) {
+	case "half-up", "":
+		return d.Round(scale), nil
+	case "half-even":
+		return d.RoundBank(scale), nil
+	case "up":
+		return d.RoundUp(scale), nil
+	case "down":
+		return d.RoundDown(scale), nil
+	case "ceil":
+		return d.RoundCeil(scale), nil
+	case "floor":
+		return d.RoundFloor(scale), nil
+	default:
+		return decimal.Zero, fmt.Errorf("unknown rounding mode %s (accepted values are half-up, half-even, up, down, ceil, floor)", mode)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestDecArithmetic(t *testing.T) {
+	if result, err := decAdd("0.1", 0.2, decimal.RequireFromString("0.3"), 1); err != nil || result.String() != "1.6" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := decSub(1, "0.9"); err != nil || result.String() != "0.1" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := decMul("1.1", "1.1", int64(2)); err != nil || result.String() != "2.42" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := decAdd(1, "abc"); err == nil || !strings.Contains(err.Error(), "decAdd: value abc is not a number") {
+		t.Errorf("result: %v", err)
+	}
+	// Chaining without float conversion
+	if err := runt(`{{decAdd "0.1" "0.2" | decMul 3 | decSub "0.9"}}`, "0"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDecDiv(t *testing.T) {
+	tests := []struct {
+		a, b     interface{}
+		options  []interface{}
+		expected string
+	}{
+		{10, 4, nil, "2.5"},
+		{1, 3, nil, "0.3333333333333333"},
+		{2, 3, []interface{}{2}, "0.67"},
+		{-2, 3, []interface{}{2}, "-0.67"},
+		{1, 8, []interface{}{2, "half-even"}, "0.12"},
+		{3, 8, []interface{}{2, "half-even"}, "0.38"},
+		{1, 8, []interface{}{2, "half-up"}, "0.13"},
+		{1, 3, []interface{}{2, "up"}, "0.34"},
+		{2, 3, []interface{}{2, "down"}, "0.66"},
+		{-1, 3, []interface{}{2, "ceil"}, "-0.33"},
+		{-1, 3, []interface{}{2, "floor"}, "-0.34"},
+		{"100.005", 1, []interface{}{"2", "half-even"}, "100"},
+	}
+	for _, test := range tests {
+		if result, err := decDiv(test.a, test.b, test.options...); err != nil || result.String() != test.expected {
+			t.Errorf("decDiv %v %v %v result: %v %v", test.a, test.b, test.options, result, err)
+		}
+	}
+	if _, err := decDiv(1, 0); err == nil || !strings.Contains(err.Error(), "decDiv: division by zero") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := decDiv(1, 3, 2, "nearest"); err == nil || !strings.Contains(err.Error(), "decDiv: unknown rounding mode") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := decDiv(1, 3, 1.5); err == nil {
+		t.Errorf("result: expected error")
+	}
+}
+
+func TestDecRound(t *testing.T) {
+	if result, err := decRound("2.345", 2); err != nil || result.String() != "2.35" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := decRound("2.345", 2, "half-even"); err != nil || result.String() != "2.34" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := decRound(-2.345, 2, "down"); err != nil || result.String() != "-2.34" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := decRound(1, 2, "x"); err == nil {
+		t.Errorf("result: expected error")
+	}
+}
+
+func TestDecCmp(t *testing.T) {
+	if result, err := decCmp("1.10", 1.1); err != nil || result != 0 {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, _ := decCmp(2, "10"); result != -1 {
+		t.Errorf("result: %v", result)
+	}
+	if _, err := decCmp("a", 1); err == nil {
+		t.Errorf("result: expected error")
+	}
+}
+
+func TestDecFormat(t *testing.T) {
+	tests := []struct {
+		value      interface{}
+		scale      int
+		separators []string
+		expected   string
+	}{
+		{1234567.891, 2, nil, "1,234,567.89"},
+		{"1234.5", 2, []string{" ", ","}, "1 234,50"},
+		{-1234.5, 0, []string{"."}, "-1.235"},
+		{"999.995", 2, nil, "1,000.00"},
+		{12, 2, []string{""}, "12.00"},
+	}
+	for _, test := range tests {
+		if result, err := decFormat(test.value, test.scale, test.separators...); err != nil || result != test.expected {
+			t.Errorf("decFormat %v result: %v %v", test.value, result, err)
+		}
+	}
+}
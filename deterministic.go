@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// bafiNamespace default namespace of UUIDv5 generated in deterministic mode
+var bafiNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/mmalcek/bafi"))
+
+var (
+	clock       = time.Now                                        // current time used by now, nowTZ (fixed by -now or -deterministic)
+	random      = rand.New(rand.NewSource(time.Now().UnixNano())) // random generator used by randInt (fixed by -seed or -deterministic)
+	randomMutex sync.Mutex                                        // guards random (rand.Rand is not goroutine-safe)
+	uuidSpace   *uuid.UUID                                        // namespace of UUIDv5 generated by uuid, nil = random UUIDv4
+	uuidCounter uint64                                            // sequence number, part of name of generated UUIDv5
+	uuidInput   string                                            // hash of input data, part of name of generated UUIDv5 (different inputs get different UUIDs)
+)
+
+// setDeterministic set random seed, clock and uuid generator by -seed, -now, -uuidns and -deterministic.
+// Deterministic mode fixes all of them (seed 0, clock 1970-01-01T00:00:00Z and default namespace if not defined).
+// Seed is nil if -seed is not defined
+func setDeterministic(params tParams) error {
+	deterministic := *params.deterministic
+	seed := time.Now().UnixNano()
+	if params.seed != nil {
+		seed = *params.seed
+	} else if deterministic {
+		seed = 0
+	}
+	randomMutex.Lock()
+	random = rand.New(rand.NewSource(seed))
+	randomMutex.Unlock()
+	clock = time.Now
+	if *params.now != "" {
+		fixed, err := parseDate(*params.now)
+		if err != nil {
+			return fmt.Errorf("now: %s", strings.TrimPrefix(err.Error(), "parseDate: "))
+		}
+		clock = func() time.Time { return fixed }
+	} else if deterministic {
+		clock = func() time.Time { return time.Unix(0, 0).UTC() }
+	}
+	uuidSpace, uuidInput = nil, ""
+	atomic.StoreUint64(&uuidCounter, 0)
+	if *params.uuidNamespace != "" || deterministic {
+		namespace := uuidNamespace(*params.uuidNamespace)
+		uuidSpace = &namespace
+	}
+	return nil
+}
+
+// setUUIDInput set hash of input data used in names of generated UUIDv5
+func setUUIDInput(hash []byte) {
+	uuidInput = hex.EncodeToString(hash)
+}
+
+// uuidName name of next generated UUIDv5: hash of input data and sequence number
+func uuidName() string {
+	sequence := strconv.FormatUint(atomic.AddUint64(&uuidCounter, 1), 10)
+	if uuidInput == "" {
+		return sequence
+	}
+	return uuidInput + ":" + sequence
+}
+
+// uuidNamespace get namespace by UUID, predefined name (dns, url, oid, x500) or any text (converted to UUIDv5 in URL namespace).
+// Empty namespace is bafi default namespace
+func uuidNamespace(name string) uuid.UUID {
+	switch strings.ToLower(name) {
+	case "":
+		return bafiNamespace
+	case "dns":
+		return uuid.NameSpaceDNS
+	case "url":
+		return uuid.NameSpaceURL
+	case "oid":
+		return uuid.NameSpaceOID
+	case "x500":
+		return uuid.NameSpaceX500
+	}
+	if namespace, err := uuid.Parse(name); err == nil {
+		return namespace
+	}
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(name))
+}
+
+// randomIntn random integer in range [0, n)
+func randomIntn(n int) int {
+	randomMutex.Lock()
+	defer randomMutex.Unlock()
+	return random.Intn(n)
+}
+
+// randomFloat random number in range [0.0, 1.0) used by lua math.random and javascript Math.random
+func randomFloat() float64 {
+	randomMutex.Lock()
+	defer randomMutex.Unlock()
+	return random.Float64()
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// deterministicParams params with determinism options only
+func deterministicParams(seed int64, now, uuidNamespace string, deterministic bool) tParams {
+	return tParams{seed: &seed, now: &now, uuidNamespace: &uuidNamespace, deterministic: &deterministic}
+}
+
+// renderDeterministic render template after determinism options are set
+func renderDeterministic(t *testing.T, params tParams, tpl string) string {
+	t.Helper()
+	if err := setDeterministic(params); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := template.Must(template.New("test").Funcs(renderFunctions(false)).Parse(tpl)).Execute(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestDeterministic(t *testing.T) {
+	t.Cleanup(func() { setDeterministic(deterministicParams(0, "", "", false)) })
+	tpl := `{{randInt 1 1000000}} {{randInt 1 1000000}} {{uuid}} {{uuid}} {{now "2006-01-02T15:04:05Z07:00"}} {{nowTZ "15:04" "Europe/Prague"}}`
+	first := renderDeterministic(t, deterministicParams(0, "", "", true), tpl)
+	if second := renderDeterministic(t, deterministicParams(0, "", "", true), tpl); first != second {
+		t.Errorf("output differs: %s != %s", first, second)
+	}
+	if result := renderDeterministic(t, deterministicParams(0, "", "", true), `{{uuid}} {{now "2006-01-02T15:04:05Z07:00"}}`); result != "43ceeeca-59f0-5fa2-8b2e-8d59cab0f3f6 1970-01-01T00:00:00Z" {
+		t.Errorf("result: %s", result)
+	}
+	// Seed only fixes random numbers
+	if renderDeterministic(t, deterministicParams(42, "", "", false), `{{randInt 1 1000000}}`) != renderDeterministic(t, deterministicParams(42, "", "", false), `{{randInt 1 1000000}}`) {
+		t.Error("seed: random numbers differ")
+	}
+	// Namespace only fixes uuid
+	ns := renderDeterministic(t, deterministicParams(0, "", "dns", false), `{{uuid}}`)
+	if ns != "b04965e6-a9bb-591f-8f8a-1adcb2c8dc39" {
+		t.Errorf("result: %s", ns)
+	}
+	if renderDeterministic(t, deterministicParams(0, "", "invoices", false), `{{uuid}}`) == ns {
+		t.Error("uuidns: namespaces generate the same uuid")
+	}
+	// Random uuid (v4) without deterministic mode
+	if result := renderDeterministic(t, deterministicParams(0, "", "", false), `{{uuid}}`); len(result) != 36 || result[14] != '4' {
+		t.Errorf("result: %s", result)
+	}
+	// Fixed clock
+	if result := renderDeterministic(t, deterministicParams(0, "2024-01-31T12:00:00Z", "", false), `{{now "02.01.2006 15:04"}} {{nowTZ "15:04" "Europe/Prague"}}`); result != "31.01.2024 12:00 13:00" {
+		t.Errorf("result: %s", result)
+	}
+	if err := setDeterministic(deterministicParams(0, "31.01.2024", "", false)); err == nil || err.Error() != "now: cannot parse 31.01.2024 by layouts iso8601" {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestDeterministicSeedAndInput(t *testing.T) {
+	t.Cleanup(func() { setDeterministic(deterministicParams(0, "", "", false)) })
+	// Explicit -seed 0 fixes random numbers without deterministic mode
+	seeded := renderDeterministic(t, deterministicParams(0, "", "", false), `{{randInt 1 1000000000}}`)
+	if result := renderDeterministic(t, deterministicParams(0, "", "", true), `{{randInt 1 1000000000}}`); result != seeded {
+		t.Errorf("seed 0: %s != %s", seeded, result)
+	}
+	// Input data hash is part of uuid name, sequence is same only for same input
+	uuids := func(input string) string {
+		t.Helper()
+		if err := setDeterministic(deterministicParams(0, "", "", true)); err != nil {
+			t.Fatal(err)
+		}
+		if input != "" {
+			hash := sha256.Sum256([]byte(input))
+			setUUIDInput(hash[:])
+		}
+		return newUUID() + " " + newUUID()
+	}
+	first := uuids(`{"invoice": 1}`)
+	if second := uuids(`{"invoice": 1}`); first != second {
+		t.Errorf("same input: %s != %s", first, second)
+	}
+	if other := uuids(`{"invoice": 2}`); other == first {
+		t.Errorf("different input: %s", other)
+	}
+	if result := uuids(""); result == first {
+		t.Errorf("no input: %s", result)
+	}
+}
+
+func TestDeterministicScripts(t *testing.T) {
+	t.Cleanup(func() {
+		setDeterministic(deterministicParams(0, "", "", false))
+		loadLuaFunctions(nil, luaOptions{})
+		loadJSFunctions(nil, jsOptions{})
+	})
+	luaScript := writeTestFile(t, "functions.lua", `
+function clock() math.randomseed(os.time()); return os.time() .. " " .. os.date("!%Y-%m-%d %H:%M") .. " " .. math.random(1, 1000000) .. " " .. math.random() end
+`)
+	jsScript := writeTestFile(t, "functions.js", `
+function clock() { return Date.now() + " " + new Date().toISOString() + " " + Math.random(); }
+`)
+	run := func(sandbox bool) (string, string) {
+		t.Helper()
+		if err := setDeterministic(deterministicParams(0, "2024-01-31T12:00:00Z", "", true)); err != nil {
+			t.Fatal(err)
+		}
+		if err := loadLuaFunctions([]string{luaScript}, luaOptions{sandbox: sandbox}); err != nil {
+			t.Fatal(err)
+		}
+		if err := loadJSFunctions([]string{jsScript}, jsOptions{sandbox: sandbox}); err != nil {
+			t.Fatal(err)
+		}
+		luaResult, err := luaF("clock")
+		if err != nil && !sandbox {
+			t.Fatal(err)
+		}
+		jsResult, err := jsF("clock")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return toString(luaResult), toString(jsResult)
+	}
+	luaFirst, jsFirst := run(false)
+	if !strings.HasPrefix(luaFirst, "1706702400 2024-01-31 12:00 ") {
+		t.Errorf("lua: %s", luaFirst)
+	}
+	if !strings.HasPrefix(jsFirst, "1706702400000 2024-01-31T12:00:00.000Z ") {
+		t.Errorf("js: %s", jsFirst)
+	}
+	if luaSecond, jsSecond := run(false); luaSecond != luaFirst || jsSecond != jsFirst {
+		t.Errorf("output differs: %s != %s, %s != %s", luaFirst, luaSecond, jsFirst, jsSecond)
+	}
+	// Sandbox has no os library, javascript still uses fixed clock and seed
+	_, jsSandbox := run(true)
+	if _, jsSecond := run(true); !strings.HasPrefix(jsSandbox, "1706702400000 ") || jsSecond != jsSandbox {
+		t.Errorf("js sandbox: %s != %s", jsSandbox, jsSecond)
+	}
+}
+
+func TestScriptKeyOrder(t *testing.T) {
+	t.Cleanup(func() {
+		loadLuaFunctions(nil, luaOptions{})
+		loadJSFunctions(nil, jsOptions{})
+	})
+	luaScript := writeTestFile(t, "functions.lua", `
+function keys(m) local result = "" for k in pairs(m) do result = result .. k end return result end
+function bafiKeys() local result = "" for k in pairs(require("bafi").dict("f", 1, "e", 2, "d", 3, "c", 4, "b", 5, "a", 6)) do result = result .. k end return result end
+`)
+	jsScript := writeTestFile(t, "functions.js", `
+function keys(m) { return Object.keys(m).join(""); }
+function bafiKeys() { return Object.keys(bafi.dict("f", 1, "e", 2, "d", 3, "c", 4, "b", 5, "a", 6)).join(""); }
+`)
+	if err := loadLuaFunctions([]string{luaScript}, luaOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadJSFunctions([]string{jsScript}, jsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{"f": 1, "e": 2, "d": 3, "c": 4, "b": 5, "a": 6}
+	for x := 0; x < 10; x++ {
+		if result, err := luaF("keys", data); err != nil || result != "abcdef" {
+			t.Fatalf("lua: %v %v", result, err)
+		}
+		if result, err := luaF("bafiKeys"); err != nil || result != "abcdef" {
+			t.Fatalf("lua bafi: %v %v", result, err)
+		}
+		if result, err := jsF("keys", data); err != nil || result != "abcdef" {
+			t.Fatalf("js: %v %v", result, err)
+		}
+		if result, err := jsF("bafiKeys"); err != nil || result != "abcdef" {
+			t.Fatalf("js bafi: %v %v", result, err)
+		}
+	}
+}
@@ -7,11 +7,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
-	"math/rand"
 	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -19,7 +19,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/spf13/cast"
-	lua "github.com/yuin/gopher-lua"
 	"go.mongodb.org/mongo-driver/bson"
 	"gopkg.in/yaml.v3"
 )
@@ -49,11 +48,34 @@ func templateFunctions() template.FuncMap {
 		"dateToInt":       dateToInt,
 		"intToDate":       intToDate,
 		"now":             now,
+		"nowTZ":           nowTZ,
+		"parseDate":       parseDate,
+		"formatDate":      formatDate,
+		"toTimezone":      toTimezone,
+		"dateAdd":         dateAdd,
+		"dateDiff":        dateDiff,
+		"startOf":         startOf,
+		"endOf":           endOf,
+		"weekday":         weekday,
+		"isoWeek":         isoWeek,
+		"isBusinessDay":   isBusinessDay,
+		"addBusinessDays": addBusinessDays,
+		"businessDays":    businessDays,
 		"b64enc":          base64encode,
 		"b64dec":          base64decode,
 		"b32enc":          base32encode,
 		"b32dec":          base32decode,
 		"uuid":            newUUID,
+		"uuidv5":          uuidv5,
+		"md5":             md5Sum,
+		"sha1":            sha1Sum,
+		"sha256":          sha256Sum,
+		"sha512":          sha512Sum,
+		"crc32":           crc32Sum,
+		"hmac":            hmacSum,
+		"hexenc":          hexEncode,
+		"hexdec":          hexDecode,
+		"jws":             jwsSign,
 		"replaceAll":      replaceAll,
 		"replaceAllRegex": replaceAllRegex,
 		"regexMatch":      regexMatch,
@@ -86,7 +108,53 @@ func templateFunctions() template.FuncMap {
 		"isArray":         isArray,
 		"mustArray":       mustArray,
 		"mapJSON":         mapJSON,
-		"lua":             luaF,
+		"lua":             luaTemplate,
+		"js":              jsF,
+		"query":           query,
+		"sortBy":          sortBy,
+		"where":           where,
+		"filter":          where,
+		"groupBy":         groupBy,
+		"uniq":            uniq,
+		"pluck":           pluck,
+		"first":           first,
+		"last":            last,
+		"reverse":         reverse,
+		"concat":          concat,
+		"keys":            keys,
+		"values":          values,
+		"sum":             sum,
+		"avg":             avg,
+		"count":           count,
+		"sumBy":           sumBy,
+		"avgBy":           avgBy,
+		"minBy":           minBy,
+		"maxBy":           maxBy,
+		"dict":            dict,
+		"list":            list,
+		"set":             set,
+		"unset":           unset,
+		"merge":           merge,
+		"pick":            pick,
+		"omit":            omit,
+		"hasKey":          hasKey,
+		"get":             get,
+		"decAdd":          decAdd,
+		"decSub":          decSub,
+		"decMul":          decMul,
+		"decDiv":          decDiv,
+		"decRound":        decRound,
+		"decCmp":          decCmp,
+		"decFormat":       decFormat,
+		"formatNumber":    formatNumber,
+		"formatCurrency":  formatCurrency,
+		"parseNumber":     parseNumber,
+		"try":             try,
+		"default":         defaultValue,
+		"writeFile":       writeFile,
+		"var":             getVar,
+		"vars":            getVars,
+		"env":             getEnv,
 	}
 }
 
@@ -150,7 +218,7 @@ func randInt(min, max int) int {
 	if max <= min {
 		return min
 	}
-	return rand.Intn(max-min+1) + min
+	return randomIntn(max-min+1) + min
 }
 
 // round float {{round .val 2}} -> 2 decimals or {{round .val 1 0.4}} 0.4 round point
@@ -219,34 +287,34 @@ func minf(a interface{}, numbers ...interface{}) float64 {
 }
 
 // dateFormat convert date format {{dateFormat "string", "inputPattern", "outputPattern"}} e.g. {{dateFormat "15.03.2021", "02.01.2006", "01022006"}}
-func dateFormat(date string, inputFormat string, outputFormat string) string {
+func dateFormat(date string, inputFormat string, outputFormat string) (string, error) {
 	timeParsed, err := time.Parse(inputFormat, date)
 	if err != nil {
-		return date
+		return strictError(date, fmt.Errorf("dateFormat: %s", err.Error()))
 	}
-	return timeParsed.Format(outputFormat)
+	return timeParsed.Format(outputFormat), nil
 }
 
-func dateFormatTZ(date string, inputFormat string, outputFormat string, timeZone string) string {
+func dateFormatTZ(date string, inputFormat string, outputFormat string, timeZone string) (string, error) {
 	location, err := time.LoadLocation(timeZone)
 	if err != nil {
-		return "err: unknownTimeZone"
+		return strictError("err: unknownTimeZone", fmt.Errorf("dateFormatTZ: %s", err.Error()))
 	}
 	timeParsed, err := time.Parse(inputFormat, date)
 	if err != nil {
-		return "err: wrongFormatDefinition"
+		return strictError("err: wrongFormatDefinition", fmt.Errorf("dateFormatTZ: %s", err.Error()))
 	}
 	timeParsed = timeParsed.In(location)
-	return timeParsed.Format(outputFormat)
+	return timeParsed.Format(outputFormat), nil
 }
 
 // convert date to unix timestamp
-func dateToInt(date string, inputFormat string) int64 {
+func dateToInt(date string, inputFormat string) (int64, error) {
 	timeParsed, err := time.Parse(inputFormat, date)
 	if err != nil {
-		return 0
+		return strictError(int64(0), fmt.Errorf("dateToInt: %s", err.Error()))
 	}
-	return timeParsed.Unix()
+	return timeParsed.Unix(), nil
 }
 
 // convert unix timestamp to date
@@ -256,7 +324,7 @@ func intToDate(unixTime interface{}, outputFormat string) string {
 
 // now return current date/time in specified format
 func now(format string) string {
-	return time.Now().Format(format)
+	return clock().Format(format)
 }
 
 // base64encode encode to base64
@@ -265,12 +333,12 @@ func base64encode(v string) string {
 }
 
 // base64decode decode from base64
-func base64decode(v string) string {
+func base64decode(v string) (string, error) {
 	data, err := base64.StdEncoding.DecodeString(v)
 	if err != nil {
-		return err.Error()
+		return strictError(err.Error(), fmt.Errorf("b64dec: %s", err.Error()))
 	}
-	return string(data)
+	return string(data), nil
 }
 
 // base32encode encode to base32
@@ -279,30 +347,41 @@ func base32encode(v string) string {
 }
 
 // base32decode decode from base32
-func base32decode(v string) string {
+func base32decode(v string) (string, error) {
 	data, err := base32.StdEncoding.DecodeString(v)
 	if err != nil {
-		return err.Error()
+		return strictError(err.Error(), fmt.Errorf("b32dec: %s", err.Error()))
 	}
-	return string(data)
+	return string(data), nil
 }
 
-// newUUID returns UUID
-func newUUID() string { return uuid.New().String() }
+// newUUID returns random UUID (v4) or UUIDv5 from namespace, input data hash and sequence number in deterministic mode (-deterministic, -uuidns)
+func newUUID() string {
+	if uuidSpace == nil {
+		return uuid.New().String()
+	}
+	return uuid.NewSHA1(*uuidSpace, []byte(uuidName())).String()
+}
 
 func replaceAll(old, new, src string) string {
 	return strings.Replace(src, old, new, -1)
 }
 
-func replaceAllRegex(regex, new, src string) string {
-	r := regexp.MustCompile(regex)
-	return r.ReplaceAllString(src, new)
+func replaceAllRegex(regex, new, src string) (string, error) {
+	r, err := regexp.Compile(regex)
+	if err != nil {
+		return "", fmt.Errorf("replaceAllRegex: %s", err.Error())
+	}
+	return r.ReplaceAllString(src, new), nil
 }
 
 // regexMatch check regex e.g. {{regexMatch "a.b", "aaxbb"}}
-func regexMatch(regex string, s string) bool {
-	match, _ := regexp.MatchString(regex, s)
-	return match
+func regexMatch(regex string, s string) (bool, error) {
+	match, err := regexp.MatchString(regex, s)
+	if err != nil {
+		return strictError(false, fmt.Errorf("regexMatch: %s", err.Error()))
+	}
+	return match, nil
 }
 
 // contains check if string contains substring e.g. {{contains "aaxbb" "xb"}}
@@ -321,19 +400,17 @@ func lower(s string) string {
 }
 
 // addSubstring add substring to string {{addSubstring "abcd", "efg", 2}} -> "abefgcd"
-func addSubstring(s string, ss string, pos interface{}) string {
+func addSubstring(s string, ss string, pos interface{}) (string, error) {
 	if toInt(pos) >= len(s) || -toInt(pos) >= len(s) {
-		return "err:substringOutOfRange"
+		return strictError("err:substringOutOfRange", fmt.Errorf("addSubstring: position %v out of range", pos))
 	}
 	switch x := toInt(pos); {
 	case x == 0:
-		return s
+		return s, nil
 	case x > 0:
-		return fmt.Sprintf("%s%s%s", s[:len(s)-x], ss, s[len(s)-x:])
-	case x < 0:
-		return fmt.Sprintf("%s%s%s", s[:-x], ss, s[-x:])
+		return fmt.Sprintf("%s%s%s", s[:len(s)-x], ss, s[len(s)-x:]), nil
 	default:
-		return "inputError"
+		return fmt.Sprintf("%s%s%s", s[:-x], ss, s[-x:]), nil
 	}
 }
 
@@ -358,7 +435,13 @@ func indexOf(a, b string) int {
 }
 
 // atoi {{atoi "42"}} - string to int
-func atoi(a string) int { i, _ := strconv.Atoi(a); return i }
+func atoi(a string) (int, error) {
+	i, err := strconv.Atoi(a)
+	if err != nil {
+		return strictError(i, fmt.Errorf("atoi: %s", err.Error()))
+	}
+	return i, nil
+}
 
 func toBool(v interface{}) bool {
 	return cast.ToBool(v)
@@ -384,52 +467,52 @@ func toFloat64(v interface{}) float64 {
 }
 
 // toDecimal input to decimal
-func toDecimal(i interface{}) decimal.Decimal {
+func toDecimal(i interface{}) (decimal.Decimal, error) {
 	value, err := convertDecimal(i)
 	if err != nil {
-		return decimal.Zero
+		return strictError(decimal.Zero, fmt.Errorf("toDecimal: %s", err.Error()))
 	}
-	return value
+	return value, nil
 }
 
 // toDecimalString input to decimal string
-func toDecimalString(i interface{}) string {
+func toDecimalString(i interface{}) (string, error) {
 	value, err := convertDecimal(i)
 	if err != nil {
-		return fmt.Sprintf("err: %s", err.Error())
+		return strictError(fmt.Sprintf("err: %s", err.Error()), fmt.Errorf("toDecimalString: %s", err.Error()))
 	}
-	return value.String()
+	return value.String(), nil
 }
 
 // toJSON convert to JSON
-func toJSON(data interface{}) string {
+func toJSON(data interface{}) (string, error) {
 	out, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
-		return fmt.Sprintf("err: %s", err.Error())
+		return strictError(fmt.Sprintf("err: %s", err.Error()), fmt.Errorf("toJSON: %s", err.Error()))
 	}
-	return string(out)
+	return string(out), nil
 }
 
 // toBSON convert to BSON
-func toBSON(data interface{}) string {
+func toBSON(data interface{}) (string, error) {
 	out, err := bson.Marshal(data)
 	if err != nil {
-		return fmt.Sprintf("err: %s", err.Error())
+		return strictError(fmt.Sprintf("err: %s", err.Error()), fmt.Errorf("toBSON: %s", err.Error()))
 	}
-	return string(out)
+	return string(out), nil
 }
 
 // toYAML convert to YAML
-func toYAML(data interface{}) string {
+func toYAML(data interface{}) (string, error) {
 	out, err := yaml.Marshal(data)
 	if err != nil {
-		return fmt.Sprintf("err: %s", err.Error())
+		return strictError(fmt.Sprintf("err: %s", err.Error()), fmt.Errorf("toYAML: %s", err.Error()))
 	}
-	return string(out)
+	return string(out), nil
 }
 
 // toXML convert to XML
-func toXML(data interface{}) string {
+func toXML(data interface{}) (string, error) {
 	var err error
 	out := []byte("<doc>\r\n")
 	if reflect.TypeOf(data).Kind() == reflect.Slice {
@@ -437,24 +520,24 @@ func toXML(data interface{}) string {
 			for i := 0; i < reflect.ValueOf(data).Len(); i++ {
 				x, err := mxj.AnyXmlIndent(data.([]map[string]interface{})[i], "", "  ", "record")
 				if err != nil {
-					return fmt.Sprintf("err: %s", err.Error())
+					return strictError(fmt.Sprintf("err: %s", err.Error()), fmt.Errorf("toXML: %s", err.Error()))
 				}
 				out = append(out, string(x)+"\r\n"...)
 			}
 			out = append(out, string("</doc>\r\n")...)
 			out, err = mxj.BeautifyXml(out, "", "  ")
 			if err != nil {
-				return fmt.Sprintf("err: %s", err.Error())
+				return strictError(fmt.Sprintf("err: %s", err.Error()), fmt.Errorf("toXML: %s", err.Error()))
 			}
-			return string(out)
+			return string(out), nil
 		}
 
 	}
 	out, err = mxj.AnyXmlIndent(data, "", "  ", "doc")
 	if err != nil {
-		return fmt.Sprintf("err: %s", err.Error())
+		return strictError(fmt.Sprintf("err: %s", err.Error()), fmt.Errorf("toXML: %s", err.Error()))
 	}
-	return string(out)
+	return string(out), nil
 }
 
 // isBool check if value is bool
@@ -505,35 +588,239 @@ func mustArray(v interface{}) []interface{} { // convert to []interface{}
 }
 
 // mapJSON string JSON to map[string]interface{} so it can be used in pipline -> template
-func mapJSON(input string) map[string]interface{} {
+func mapJSON(input string) (map[string]interface{}, error) {
 	var mapData map[string]interface{}
 	if err := json.Unmarshal([]byte(input), &mapData); err != nil {
 		testData := make(map[string]interface{})
 		testData["error"] = err.Error()
-		return testData
+		return strictError(testData, fmt.Errorf("mapJSON: %s", err.Error()))
+	}
+	return mapData, nil
+}
+
+var (
+	functionsOnce sync.Once        // builds functionMap on first use
+	functionMap   template.FuncMap // template functions called by try
+)
+
+// try call template function and ignore its error {{try "b64dec" .Value}} -> result or nil if function fails. Use with default e.g. {{try "b64dec" .Value | default "n/a"}}
+// Functions with fallback values (e.g. b64dec returns error message) fail inside try as in strict mode
+func try(name string, args ...interface{}) (interface{}, error) {
+	functionsOnce.Do(func() { functionMap = templateFunctions() })
+	fn, ok := functionMap[name]
+	if !ok {
+		return nil, fmt.Errorf("try: unknown function %s", name)
+	}
+	return tryCall(fn, args), nil
+}
+
+// tryCall call function by try, nil is returned if function fails
+func tryCall(fn interface{}, args []interface{}) interface{} {
+	result, err := callFunction(fn, args)
+	if err != nil {
+		return nil
+	}
+	return result
+}
+
+// scriptTry try of lua and js bafi functions. Caller already holds script engine, so lua and js functions
+// don't wait for free engine (nested calls fail if all engines are in use instead of deadlock)
+func scriptTry(name string, args ...interface{}) (interface{}, error) {
+	switch name {
+	case "lua":
+		return tryCall(luaNested, args), nil
+	case "js":
+		return tryCall(jsNested, args), nil
+	}
+	return try(name, args...)
+}
+
+// sandboxFunctions template functions available in sandbox (-luasandbox, -jssandbox), functions with access to files,
+// environment or scripts (writeFile, env, hmac, jws, lua, js) are not listed
+var sandboxFunctions = map[string]bool{
+	"add": true, "add1": true, "sub": true, "div": true, "mod": true, "mul": true,
+	"addf": true, "add1f": true, "subf": true, "divf": true, "mulf": true,
+	"randInt": true, "round": true, "max": true, "min": true, "maxf": true, "minf": true,
+	"dateFormat": true, "dateFormatTZ": true, "dateToInt": true, "intToDate": true, "now": true, "nowTZ": true,
+	"parseDate": true, "formatDate": true, "toTimezone": true, "dateAdd": true, "dateDiff": true, "startOf": true, "endOf": true,
+	"weekday": true, "isoWeek": true, "isBusinessDay": true, "addBusinessDays": true, "businessDays": true,
+	"b64enc": true, "b64dec": true, "b32enc": true, "b32dec": true, "uuid": true, "uuidv5": true,
+	"md5": true, "sha1": true, "sha256": true, "sha512": true, "crc32": true, "hexenc": true, "hexdec": true,
+	"replaceAll": true, "replaceAllRegex": true, "regexMatch": true, "contains": true, "upper": true, "lower": true,
+	"addSubstring": true, "trim": true, "trimAll": true, "trimSuffix": true, "indexOf": true, "trimPrefix": true,
+	"atoi": true, "toBool": true, "toString": true, "toInt": true, "toInt64": true, "toFloat64": true,
+	"toDecimal": true, "toDecimalString": true, "toJSON": true, "toBSON": true, "toYAML": true, "toXML": true,
+	"isBool": true, "isInt": true, "isFloat64": true, "isString": true, "isMap": true, "isArray": true, "mustArray": true,
+	"mapJSON": true, "query": true, "sortBy": true, "where": true, "filter": true, "groupBy": true, "uniq": true,
+	"pluck": true, "first": true, "last": true, "reverse": true, "concat": true, "keys": true, "values": true,
+	"sum": true, "avg": true, "count": true, "sumBy": true, "avgBy": true, "minBy": true, "maxBy": true,
+	"dict": true, "list": true, "set": true, "unset": true, "merge": true, "pick": true, "omit": true, "hasKey": true, "get": true,
+	"decAdd": true, "decSub": true, "decMul": true, "decDiv": true, "decRound": true, "decCmp": true, "decFormat": true,
+	"formatNumber": true, "formatCurrency": true, "parseNumber": true, "try": true, "default": true, "var": true, "vars": true,
+}
+
+// sandboxTry try limited to sandboxFunctions, used as try in sandbox
+func sandboxTry(name string, args ...interface{}) (interface{}, error) {
+	if !sandboxFunctions[name] {
+		return nil, fmt.Errorf("try: function %s is not available in sandbox", name)
+	}
+	return try(name, args...)
+}
+
+// scriptFunctions template functions of bafi module in lua and js (lua and js functions are excluded), sandbox gets only sandboxFunctions
+func scriptFunctions(sandbox bool) map[string]interface{} {
+	functions := make(map[string]interface{})
+	for name, fn := range templateFunctions() {
+		if name == "lua" || name == "js" || (sandbox && !sandboxFunctions[name]) {
+			continue
+		}
+		functions[name] = fn
+	}
+	if sandbox {
+		functions["try"] = sandboxTry
+	} else {
+		functions["try"] = scriptTry
+	}
+	return functions
+}
+
+// defaultValue return default if value is empty (nil, "", 0, false, empty array/map) {{.Value | default "n/a"}}
+func defaultValue(d interface{}, value ...interface{}) interface{} {
+	if len(value) == 0 || isEmpty(value[0]) {
+		return d
+	}
+	return value[0]
+}
+
+// isEmpty check if value is nil or zero value of its type or empty array/map
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	default:
+		return value.IsZero()
+	}
+}
+
+// callFunction call function with arguments converted to function parameter types, panic is returned as error
+func callFunction(fn interface{}, args []interface{}) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	fnValue := reflect.ValueOf(fn)
+	fnType := fnValue.Type()
+	numIn := fnType.NumIn()
+	if fnType.IsVariadic() {
+		if len(args) < numIn-1 {
+			return nil, fmt.Errorf("wrong number of args: got %d want at least %d", len(args), numIn-1)
+		}
+	} else if len(args) != numIn {
+		return nil, fmt.Errorf("wrong number of args: got %d want %d", len(args), numIn)
+	}
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var argType reflect.Type
+		if fnType.IsVariadic() && i >= numIn-1 {
+			argType = fnType.In(numIn - 1).Elem()
+		} else {
+			argType = fnType.In(i)
+		}
+		if in[i], err = convertArgument(arg, argType); err != nil {
+			return nil, fmt.Errorf("arg %d: %s", i, err.Error())
+		}
+	}
+	out := fnValue.Call(in)
+	if len(out) == 2 && !out[1].IsNil() {
+		return nil, out[1].Interface().(error)
 	}
-	return mapData
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out[0].Interface(), nil
 }
 
-// luaF Call LUA function {{lua "functionName" input1 input2 input3 ...}
-// 1. Functions must be placed in ./lua/functions, 2. Inputs are passed as stringified json 3. Output of lua function must be string
-func luaF(i ...interface{}) string {
-	if luaData == nil {
-		return "error: ./lua/functions.lua file missing)"
+// convertArgument convert value to reflect.Value of defined type
+func convertArgument(arg interface{}, argType reflect.Type) (reflect.Value, error) {
+	if arg == nil {
+		return reflect.Zero(argType), nil
+	}
+	value := reflect.ValueOf(arg)
+	if value.Type().AssignableTo(argType) {
+		return value, nil
+	}
+	var converted interface{}
+	var err error
+	switch argType.Kind() {
+	case reflect.String:
+		converted, err = cast.ToStringE(arg)
+	case reflect.Bool:
+		converted, err = cast.ToBoolE(arg)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		converted, err = cast.ToInt64E(arg)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		converted, err = cast.ToUint64E(arg)
+	case reflect.Float32, reflect.Float64:
+		converted, err = cast.ToFloat64E(arg)
+	default:
+		return reflect.Value{}, fmt.Errorf("can't convert %T to %s", arg, argType.String())
 	}
-	strData, err := json.Marshal(i[1:])
 	if err != nil {
-		return fmt.Sprintf("luaInputError: %s\r\n", err.Error())
+		return reflect.Value{}, err
 	}
-	if err := luaData.CallByParam(
-		lua.P{Fn: luaData.GetGlobal(i[0].(string)), NRet: 1, Protect: true}, lua.LString(string(strData))); err != nil {
-		return fmt.Sprintf("luaError: %s\r\n", err.Error())
+	return reflect.ValueOf(converted).Convert(argType), nil
+}
+
+// fallbackError error of function with fallback value, templates get fallback value instead of error outside strict mode (renderFunctions)
+type fallbackError struct {
+	err error
+}
+
+// Error implements error
+func (e *fallbackError) Error() string {
+	return e.err.Error()
+}
+
+// strictError return fallback value (legacy behavior) with error. Template gets fallback value outside strict mode (-strict),
+// error is returned in strict mode, inside try and in lua and js bafi functions
+func strictError[T any](fallback T, err error) (T, error) {
+	return fallback, &fallbackError{err: err}
+}
+
+// renderFunctions template functions used by rendered template. Outside strict mode functions with fallback values
+// return fallback value instead of error (legacy behavior), strictness is set per render and doesn't affect try, lua and js
+func renderFunctions(strict bool) template.FuncMap {
+	functions := templateFunctions()
+	if strict {
+		return functions
 	}
-	if str, ok := luaData.Get(-1).(lua.LString); ok {
-		luaData.Pop(1)
-		return str.String()
+	for name, fn := range functions {
+		fnValue := reflect.ValueOf(fn)
+		fnType := fnValue.Type()
+		if fnType.NumOut() != 2 {
+			continue
+		}
+		functions[name] = reflect.MakeFunc(fnType, func(in []reflect.Value) []reflect.Value {
+			var out []reflect.Value
+			if fnType.IsVariadic() {
+				out = fnValue.CallSlice(in)
+			} else {
+				out = fnValue.Call(in)
+			}
+			if _, ok := out[1].Interface().(*fallbackError); ok {
+				out[1] = reflect.Zero(fnType.Out(1))
+			}
+			return out
+		}).Interface()
 	}
-	return "luaError: getResult"
+	return functions
 }
 
 // execDecimalOp convert float to decimal
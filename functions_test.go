@@ -18,7 +18,7 @@ func runt(tpl, expect string) error {
 }
 
 func runtv(tpl, expect string, vars interface{}) error {
-	t := template.Must(template.New("test").Funcs(templateFunctions()).Parse(tpl))
+	t := template.Must(template.New("test").Funcs(renderFunctions(strictMode)).Parse(tpl))
 	var b bytes.Buffer
 	err := t.Execute(&b, vars)
 	if err != nil {
@@ -164,29 +164,29 @@ func TestMinf(t *testing.T) {
 }
 
 func TestDateFormat(t *testing.T) {
-	if dateFormat("15.03.2021", "02.01.2006", "01022006") != "03152021" {
-		t.Errorf("result: %s", dateFormat("15.03.2021", "02.01.2006", "01022006"))
+	if result, _ := dateFormat("15.03.2021", "02.01.2006", "01022006"); result != "03152021" {
+		t.Errorf("result: %s", result)
 	}
-	if dateFormat("Hello", "World", "01022006") != "Hello" {
-		t.Errorf("result: %s", dateFormat("Hello", "World", "01022006"))
+	if result, _ := dateFormat("Hello", "World", "01022006"); result != "Hello" {
+		t.Errorf("result: %s", result)
 	}
 }
 
 func TestDateFormatTZ(t *testing.T) {
-	if dateFormatTZ("2021-08-26T03:35:00.000+04:00", "2006-01-02T15:04:05.000-07:00", "15:04", "Europe/Prague") != "01:35" {
-		t.Errorf("result: %s", dateFormatTZ("2021-08-26T03:35:00.000+04:00", "2006-01-02T15:04:05.000-07:00", "15:04", "Europe/Prague"))
+	if result, _ := dateFormatTZ("2021-08-26T03:35:00.000+04:00", "2006-01-02T15:04:05.000-07:00", "15:04", "Europe/Prague"); result != "01:35" {
+		t.Errorf("result: %s", result)
 	}
-	if dateFormatTZ("Hello", "World", "01022006", "42") != "err: unknownTimeZone" {
-		t.Errorf("result: %s", dateFormatTZ("Hello", "World", "01022006", "42"))
+	if result, _ := dateFormatTZ("Hello", "World", "01022006", "42"); result != "err: unknownTimeZone" {
+		t.Errorf("result: %s", result)
 	}
-	if dateFormatTZ("Hello", "World", "01022006", "Europe/Prague") != "err: wrongFormatDefinition" {
-		t.Errorf("result: %s", dateFormatTZ("Hello", "World", "01022006", "Europe/Prague"))
+	if result, _ := dateFormatTZ("Hello", "World", "01022006", "Europe/Prague"); result != "err: wrongFormatDefinition" {
+		t.Errorf("result: %s", result)
 	}
 }
 
 func TestDateToInt(t *testing.T) {
-	if dateToInt("15.03.2021", "02.01.2006") != 1615766400 {
-		t.Errorf("result: %v", dateToInt("15.03.2021", "02.01.2006"))
+	if result, _ := dateToInt("15.03.2021", "02.01.2006"); result != 1615766400 {
+		t.Errorf("result: %v", result)
 	}
 }
 
@@ -209,11 +209,11 @@ func TestBase64encode(t *testing.T) {
 }
 
 func TestBase64decode(t *testing.T) {
-	if base64decode("SGVsbG8gV29ybGQh") != "Hello World!" {
-		t.Errorf("result: %v", base64decode("SGVsbG8gV29ybGQh"))
+	if result, _ := base64decode("SGVsbG8gV29ybGQh"); result != "Hello World!" {
+		t.Errorf("result: %v", result)
 	}
-	if base64decode("Hello") != "illegal base64 data at input byte 4" {
-		t.Errorf("result: %v", base64decode("Hello"))
+	if result, _ := base64decode("Hello"); result != "illegal base64 data at input byte 4" {
+		t.Errorf("result: %v", result)
 	}
 }
 
@@ -224,17 +224,17 @@ func TestBase32encode(t *testing.T) {
 }
 
 func TestBase32decode(t *testing.T) {
-	if base32decode("JBSWY3DPEBLW64TMMQQQ====") != "Hello World!" {
-		t.Errorf("result: %v", base32decode("JBSWY3DPEBLW64TMMQQQ===="))
+	if result, _ := base32decode("JBSWY3DPEBLW64TMMQQQ===="); result != "Hello World!" {
+		t.Errorf("result: %v", result)
 	}
-	if base32decode("Hello") != "illegal base32 data at input byte 1" {
-		t.Errorf("result: %v", base32decode("Hello"))
+	if result, _ := base32decode("Hello"); result != "illegal base32 data at input byte 1" {
+		t.Errorf("result: %v", result)
 	}
 }
 
 func TestRegexMatch(t *testing.T) {
-	if !regexMatch("a.b", "aaxbb") {
-		t.Errorf("result: %v", regexMatch(`^a.b$`, "aaxbb"))
+	if result, _ := regexMatch("a.b", "aaxbb"); !result {
+		t.Errorf("result: %v", result)
 	}
 }
 
@@ -254,8 +254,11 @@ func TestReplaceAll(t *testing.T) {
 }
 
 func TestReplaceAllRegex(t *testing.T) {
-	if replaceAllRegex("[a-d]", "Z", "aaxbb") != "ZZxZZ" {
-		t.Errorf("result: %v", replaceAllRegex("aaxbb", `[a-d]`, "Z"))
+	if result, _ := replaceAllRegex("[a-d]", "Z", "aaxbb"); result != "ZZxZZ" {
+		t.Errorf("result: %v", result)
+	}
+	if _, err := replaceAllRegex("[a-d", "Z", "aaxbb"); err == nil {
+		t.Errorf("result: %v", err)
 	}
 }
 
@@ -280,17 +283,17 @@ func TestLower(t *testing.T) {
 }
 
 func TestAddSubstring(t *testing.T) {
-	if addSubstring("Hello!!!", " World", "3") != "Hello World!!!" {
-		t.Errorf("result: %v", addSubstring("Hello!!!", " World", "3"))
+	if result, _ := addSubstring("Hello!!!", " World", "3"); result != "Hello World!!!" {
+		t.Errorf("result: %v", result)
 	}
-	if addSubstring("Hello!!!", " World", "-5") != "Hello World!!!" {
-		t.Errorf("result: %v", addSubstring("Hello!!!", " World", "-5"))
+	if result, _ := addSubstring("Hello!!!", " World", "-5"); result != "Hello World!!!" {
+		t.Errorf("result: %v", result)
 	}
-	if addSubstring("Hello!!!", " World", "0") != "Hello!!!" {
-		t.Errorf("result: %v", addSubstring("Hello!!!", " World", "0"))
+	if result, _ := addSubstring("Hello!!!", " World", "0"); result != "Hello!!!" {
+		t.Errorf("result: %v", result)
 	}
-	if addSubstring("Hello!!!", " World", "15") != "err:substringOutOfRange" {
-		t.Errorf("result: %v", addSubstring("Hello!!!", " World", "15"))
+	if result, _ := addSubstring("Hello!!!", " World", "15"); result != "err:substringOutOfRange" {
+		t.Errorf("result: %v", result)
 	}
 }
 
@@ -323,7 +326,7 @@ func TestSuffix(t *testing.T) {
 }
 
 func TestAtoi(t *testing.T) {
-	result := atoi("42")
+	result, _ := atoi("42")
 	if result != 42 {
 		t.Errorf("result: %v", result)
 	}
@@ -367,48 +370,33 @@ func TestToFloat64(t *testing.T) {
 
 func TestToDecimal(t *testing.T) {
 	x, _ := decimal.NewFromString("1234567.151234")
-	if !x.Equal(toDecimal("1234567.151234")) {
-		t.Errorf("result: %v", toDecimal("1234567.151234"))
+	if result, _ := toDecimal("1234567.151234"); !x.Equal(result) {
+		t.Errorf("result: %v", result)
 	}
 	x, _ = decimal.NewFromString("0")
-	if !x.Equal(toDecimal("1234567.151234a")) {
-		t.Errorf("result: %v", toDecimal("1234567.151234a"))
+	if result, _ := toDecimal("1234567.151234a"); !x.Equal(result) {
+		t.Errorf("result: %v", result)
 	}
 }
 
 func TestToDecimalString(t *testing.T) {
-	if toDecimalString("1234567.151234a") != "err: can't convert 1234567.151234a to decimal" {
-		t.Errorf("result: %v", toDecimalString("1234567.151234a"))
-	}
-	if toDecimalString("1234567.151234") != "1234567.151234" {
-		t.Errorf("result: %v", toDecimalString("1234567.151234"))
-	}
-}
-
-func TestLuaF(t *testing.T) {
-	if luaF("sum", "5", "5") != "10" {
-		t.Errorf("result: %s", luaF("sum", "5", "5"))
-	}
-	if !strings.Contains(luaF("Unknown", "5", "5"), `attempt to call a non-function object`) {
-		t.Errorf("result: %s", luaF("Unknown", "5", "5"))
+	if result, _ := toDecimalString("1234567.151234a"); result != "err: can't convert 1234567.151234a to decimal" {
+		t.Errorf("result: %v", result)
 	}
-	testData := make(map[string]interface{})
-	testData["Hello"] = make(chan int)
-	if !strings.Contains(luaF("sum", testData), "luaInputError: json: unsupported type: chan int") {
-		t.Errorf("result: %v", luaF("sum", testData))
+	if result, _ := toDecimalString("1234567.151234"); result != "1234567.151234" {
+		t.Errorf("result: %v", result)
 	}
 }
 
 func TestToJSON(t *testing.T) {
 	testData := make(map[string]interface{})
 	testData["Hello"] = "World"
-	result := toJSON(testData)
+	result, _ := toJSON(testData)
 	if !strings.Contains(result, `"Hello": "World"`) {
 		t.Errorf("result: %v", result)
 	}
 	testData["Hello"] = make(chan int)
-	result = toJSON(testData)
-	if result != "err: json: unsupported type: chan int" {
+	if result, _ := toJSON(testData); result != "err: json: unsupported type: chan int" {
 		t.Errorf("result: %v", result)
 	}
 }
@@ -416,13 +404,12 @@ func TestToJSON(t *testing.T) {
 func TestToBSON(t *testing.T) {
 	testData := make(map[string]interface{})
 	testData["h"] = "w"
-	result := toBSON(testData)
+	result, _ := toBSON(testData)
 	if result != string([]byte{14, 0, 0, 0, 2, 104, 0, 2, 0, 0, 0, 119, 0, 0}) {
 		t.Errorf("result: %v", []byte(result))
 	}
 	testData["Hello"] = make(chan int)
-	result = toBSON(testData)
-	if result != "err: no encoder found for chan int" {
+	if result, _ := toBSON(testData); result != "err: no encoder found for chan int" {
 		t.Errorf("result: %v", result)
 	}
 }
@@ -430,7 +417,7 @@ func TestToBSON(t *testing.T) {
 func TestToYAML(t *testing.T) {
 	testData := make(map[string]interface{})
 	testData["Hello"] = "World"
-	result := toYAML(testData)
+	result, _ := toYAML(testData)
 	if result != `Hello: World
 ` {
 		t.Errorf("result: %v", result)
@@ -440,14 +427,14 @@ func TestToYAML(t *testing.T) {
 func TestToXML(t *testing.T) {
 	testData := make(map[string]interface{})
 	testData["Hello"] = "World"
-	result := toXML(testData)
+	result, _ := toXML(testData)
 	if !strings.Contains(result, "<Hello>World</Hello>") {
 		t.Errorf("result: %v", result)
 	}
 	testData2 := make([]map[string]interface{}, 1)
 	testData2[0] = make(map[string]interface{})
 	testData2[0]["Hello"] = "World"
-	result = toXML(testData2)
+	result, _ = toXML(testData2)
 	if !strings.Contains(result, "<Hello>World</Hello>") {
 		t.Errorf("result: %v", result)
 	}
@@ -522,17 +509,129 @@ func TestMustArray(t *testing.T) {
 
 func TestMapJSON(t *testing.T) {
 	testData := "{\"Hello\":\"World\"}"
-	result := mapJSON(testData)
+	result, _ := mapJSON(testData)
 	if result["Hello"] != "World" {
 		t.Errorf("result: %v", result["Hello"])
 	}
 	testData = "{\"Hello\" World\"}"
-	result = mapJSON(testData)
-	if !strings.Contains(result["error"].(string), `invalid character 'W'`) {
+	if result, _ := mapJSON(testData); !strings.Contains(result["error"].(string), `invalid character 'W'`) {
+		t.Errorf("result: %v", result)
+	}
+}
+
+// renderCall call function as rendered template outside strict mode (functions with fallback values return fallback value)
+func renderCall(name string, args ...interface{}) (interface{}, error) {
+	return callFunction(renderFunctions(false)[name], args)
+}
+
+func TestStrictError(t *testing.T) {
+	if result, err := renderCall("dateFormat", "Hello", "World", "01022006"); result != "Hello" || err != nil {
+		t.Errorf("result: %v, %v", result, err)
+	}
+	// Strictness is set per render, strict and non-strict renders don't affect each other
+	strict, legacy := renderFunctions(true), renderFunctions(false)
+	if _, err := callFunction(strict["b64dec"], []interface{}{"Hello"}); err == nil {
+		t.Errorf("result: expected error")
+	}
+	if result, err := callFunction(legacy["b64dec"], []interface{}{"Hello"}); err != nil || result != "illegal base64 data at input byte 4" {
+		t.Errorf("result: %v, %v", result, err)
+	}
+	// Functions called directly (try, lua and js bafi functions) return error
+	strictMode = true
+	defer func() { strictMode = false }()
+	if _, err := dateFormat("Hello", "World", "01022006"); err == nil || !strings.Contains(err.Error(), "dateFormat: parsing time") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := dateToInt("Hello", "02.01.2006"); err == nil || !strings.Contains(err.Error(), "dateToInt: parsing time") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := toDecimal("1234567.151234a"); err == nil || err.Error() != "toDecimal: can't convert 1234567.151234a to decimal" {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := atoi("42a"); err == nil {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := dateFormatTZ("Hello", "World", "01022006", "42"); err == nil || err.Error() != "dateFormatTZ: unknown time zone 42" {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := base64decode("Hello"); err == nil || err.Error() != "b64dec: illegal base64 data at input byte 4" {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := base32decode("Hello"); err == nil || err.Error() != "b32dec: illegal base32 data at input byte 1" {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := addSubstring("Hello!!!", " World", "15"); err == nil || err.Error() != "addSubstring: position 15 out of range" {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := toDecimalString("1234567.151234a"); err == nil || err.Error() != "toDecimalString: can't convert 1234567.151234a to decimal" {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := toJSON(map[string]interface{}{"Hello": make(chan int)}); err == nil || err.Error() != "toJSON: json: unsupported type: chan int" {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := mapJSON(`{"Hello" World"}`); err == nil || !strings.Contains(err.Error(), `mapJSON: invalid character 'W'`) {
+		t.Errorf("result: %v", err)
+	}
+	if err := runt(`{{ b64dec "Hello" }}`, ""); err == nil || !strings.Contains(err.Error(), `test:1:3: executing "test" at <b64dec "Hello">: error calling b64dec: b64dec: illegal base64 data`) {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestTry(t *testing.T) {
+	if err := runt(`{{ try "b64dec" "SGVsbG8gV29ybGQh" }}`, "Hello World!"); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if err := runt(`{{ try "b64dec" "Hello" | default "invalid" }}`, "invalid"); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if err := runt(`{{ try "addSubstring" "Hello!!!" " World" 3 }}`, "Hello World!!!"); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if err := runt(`{{ try "unknown" "Hello" }}`, ""); err == nil || !strings.Contains(err.Error(), "try: unknown function unknown") {
+		t.Errorf("result: %v", err)
+	}
+	// Fallback value is returned outside try
+	if err := runt(`{{ b64dec "Hello" }}`, "illegal base64 data at input byte 4"); err != nil {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestDefaultValue(t *testing.T) {
+	if defaultValue("n/a", "") != "n/a" || defaultValue("n/a") != "n/a" || defaultValue("n/a", nil) != "n/a" || defaultValue(1, 0) != 1 {
+		t.Errorf("result: %v", defaultValue("n/a", ""))
+	}
+	if defaultValue("n/a", "Hello") != "Hello" || defaultValue(1, 5) != 5 {
+		t.Errorf("result: %v", defaultValue("n/a", "Hello"))
+	}
+	if result := defaultValue("n/a", []interface{}{}); result != "n/a" {
 		t.Errorf("result: %v", result)
 	}
 }
 
+func TestCallFunction(t *testing.T) {
+	result, err := callFunction(addSubstring, []interface{}{"Hello!!!", " World", 3})
+	if err != nil || result != "Hello World!!!" {
+		t.Errorf("result: %v, %v", result, err)
+	}
+	result, err = callFunction(add, []interface{}{1, "2", 3.0})
+	if err != nil || result != int64(6) {
+		t.Errorf("result: %v, %v", result, err)
+	}
+	if _, err = callFunction(upper, []interface{}{}); err == nil || err.Error() != "wrong number of args: got 0 want 1" {
+		t.Errorf("result: %v", err)
+	}
+	if _, err = callFunction(randInt, []interface{}{"x", 1}); err == nil || !strings.Contains(err.Error(), "arg 0:") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err = callFunction(base64decode, []interface{}{"Hello"}); err == nil || err.Error() != "b64dec: illegal base64 data at input byte 4" {
+		t.Errorf("result: %v", err)
+	}
+	// Template function returns fallback value outside strict mode
+	if result, err := callFunction(renderFunctions(false)["b64dec"], []interface{}{"Hello"}); err != nil || result != "illegal base64 data at input byte 4" {
+		t.Errorf("result: %v, %v", result, err)
+	}
+}
+
 func TestExecDecimalOp(t *testing.T) {
 	testMulf := func(a interface{}, v ...interface{}) float64 {
 		return execDecimalOp(a, v, func(d1, d2 decimal.Decimal) decimal.Decimal { return d1.Mul(d2) })
@@ -584,5 +683,30 @@ func TestConvertDecimal(t *testing.T) {
 
 }
 
+func TestSandboxFunctions(t *testing.T) {
+	functions := templateFunctions()
+	for name := range sandboxFunctions {
+		if _, ok := functions[name]; !ok {
+			t.Errorf("sandbox function %s is not template function", name)
+		}
+	}
+	for _, name := range []string{"writeFile", "env", "hmac", "jws", "lua", "js"} {
+		if _, ok := scriptFunctions(true)[name]; ok {
+			t.Errorf("function %s is available in sandbox", name)
+		}
+	}
+	if _, ok := scriptFunctions(false)["lua"]; ok {
+		t.Error("lua function is available in script")
+	}
+	for _, name := range []string{"writeFile", "env", "hmac", "jws", "lua", "js"} {
+		if _, err := sandboxTry(name, "a.txt", "Hello"); err == nil || err.Error() != "try: function "+name+" is not available in sandbox" {
+			t.Errorf("result: %v", err)
+		}
+	}
+	if result, err := sandboxTry("upper", "ok"); err != nil || result != "OK" {
+		t.Errorf("result: %v %v", result, err)
+	}
+}
+
 // go test -coverprofile cover.out
 // go tool cover -html='cover.out'
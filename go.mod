@@ -1,11 +1,16 @@
 module github.com/mmalcek/bafi
 
-go 1.23
+go 1.25.4
 
 require (
+	github.com/PaesslerAG/gval v1.0.0
+	github.com/PaesslerAG/jsonpath v0.1.1
 	github.com/clbanning/mxj/v2 v2.7.0
+	github.com/dop251/goja v0.0.0-20260917113740-793a2a65c13b
 	github.com/google/uuid v1.6.0
+	github.com/jacoelho/xsd v0.0.28
 	github.com/mmalcek/mt940 v0.1.1
+	github.com/santhosh-tekuri/jsonschema/v6 v6.0.2
 	github.com/sashabaranov/go-openai v1.38.0
 	github.com/shopspring/decimal v1.4.0
 	github.com/spf13/cast v1.7.1
@@ -13,3 +18,10 @@ require (
 	go.mongodb.org/mongo-driver v1.17.3
 	gopkg.in/yaml.v3 v3.0.1
 )
+
+require (
+	github.com/dlclark/regexp2/v2 v2.5.2 // indirect
+	github.com/go-sourcemap/sourcemap v2.1.3+incompatible // indirect
+	github.com/google/pprof v0.0.0-20230207041349-798e818bf904 // indirect
+	golang.org/x/text v0.17.0 // indirect
+)
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// defaultJSFile used if no javascript files are defined by -js or BAFI_JS_PATH
+const defaultJSFile = "./js/functions.js"
+
+// jsOptions javascript runtime configuration
+type jsOptions struct {
+	sandbox bool          // -jssandbox: bafi object contains only sandbox functions (no file or environment access)
+	timeout time.Duration // -jstimeout: max duration of single call (0 = unlimited)
+}
+
+// jsPool pool of javascript runtimes with loaded scripts (goja.Runtime is not goroutine-safe, each call checks out own runtime)
+type jsPool struct {
+	*scriptPool[*goja.Runtime]
+	files   []string  // javascript files loaded to each runtime
+	options jsOptions // runtime configuration
+}
+
+var jsRuntimes *jsPool // nil if no javascript files are loaded
+
+// jsFiles get list of javascript files/directories. Parameter -js has priority, then BAFI_JS_PATH environment variable
+// (list separated by OS path list separator) and default ./js/functions.js if exists
+func jsFiles(params tParams) []string {
+	var list []string
+	if params.jsFiles != nil {
+		list = *params.jsFiles
+	}
+	return scriptFiles(list, "BAFI_JS_PATH", defaultJSFile, "")
+}
+
+// loadJSFunctions load javascript files (directories are expanded to *.js files sorted by name), empty list disables js
+func loadJSFunctions(paths []string, options jsOptions) error {
+	jsRuntimes = nil
+	if len(paths) == 0 {
+		return nil
+	}
+	files, err := expandScriptFiles(paths, ".js")
+	if err != nil {
+		return fmt.Errorf("loadJS: %s", err.Error())
+	}
+	pool := &jsPool{files: files, options: options}
+	runtimes, err := newScriptPool("javascript runtimes", pool.newRuntime, nil)
+	if err != nil {
+		return err
+	}
+	pool.scriptPool = runtimes
+	jsRuntimes = pool
+	return nil
+}
+
+// newRuntime create javascript runtime with bafi object (template functions, sandbox gets only sandbox functions) and load scripts
+func (p *jsPool) newRuntime() (*goja.Runtime, error) {
+	vm := goja.New()
+	vm.SetTimeSource(func() time.Time { return clock() }) // Date follows -now and -deterministic
+	vm.SetRandSource(randomFloat)                         // Math.random follows -seed and -deterministic
+	bafi := vm.NewObject()
+	for name, fn := range scriptFunctions(p.options.sandbox) {
+		if err := bafi.Set(name, jsBafiFunction(vm, name, fn)); err != nil {
+			return nil, fmt.Errorf("loadJS: %s", err.Error())
+		}
+	}
+	if err := vm.Set("bafi", bafi); err != nil {
+		return nil, fmt.Errorf("loadJS: %s", err.Error())
+	}
+	for _, file := range p.files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("loadJS: %s", err.Error())
+		}
+		stop := setJSTimeout(vm, p.options.timeout)
+		_, err = vm.RunScript(file, string(src))
+		stop()
+		if err != nil {
+			return nil, fmt.Errorf("loadJS: %s", jsErrorMessage(err, p.options.timeout))
+		}
+	}
+	return vm, nil
+}
+
+// jsBafiFunction wrap template function to javascript function, errors are thrown as javascript exceptions
+func jsBafiFunction(vm *goja.Runtime, name string, fn interface{}) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		args := make([]interface{}, len(call.Arguments))
+		for i, arg := range call.Arguments {
+			args[i] = arg.Export()
+		}
+		result, err := callFunction(fn, args)
+		if err != nil {
+			panic(vm.NewGoError(fmt.Errorf("bafi.%s: %s", name, err.Error())))
+		}
+		value, err := plainValue(result)
+		if err != nil {
+			panic(vm.NewGoError(fmt.Errorf("bafi.%s: %s", name, err.Error())))
+		}
+		return jsValue(vm, value)
+	}
+}
+
+// jsValue convert plain value (result of plainValue) to javascript value. Object keys are set in sorted order,
+// so Object.keys and for...in iterate in the same order on every run
+func jsValue(vm *goja.Runtime, v interface{}) goja.Value {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		object := vm.NewObject()
+		for _, key := range sortedKeys(value) {
+			object.Set(key, jsValue(vm, value[key]))
+		}
+		return object
+	case []interface{}:
+		items := make([]interface{}, len(value))
+		for x, item := range value {
+			items[x] = jsValue(vm, item)
+		}
+		return vm.NewArray(items...)
+	}
+	return vm.ToValue(v)
+}
+
+// setJSTimeout interrupt javascript execution after timeout, returned function must be called after execution
+func setJSTimeout(vm *goja.Runtime, timeout time.Duration) func() {
+	if timeout <= 0 {
+		return func() {}
+	}
+	timer := time.AfterFunc(timeout, func() { vm.Interrupt("timeout") })
+	return func() {
+		timer.Stop()
+		vm.ClearInterrupt()
+	}
+}
+
+// jsErrorMessage replace interrupt error by readable timeout message
+func jsErrorMessage(err error, timeout time.Duration) string {
+	if _, ok := err.(*goja.InterruptedError); ok {
+		return fmt.Sprintf("timeout after %s (-jstimeout)", timeout)
+	}
+	return err.Error()
+}
+
+// jsF Call javascript function {{js "functionName" input1 input2 input3 ...}}
+// Inputs are passed as native javascript values (objects, arrays, numbers, strings, booleans, null),
+// returned value is converted back to map, array, number, string, bool. If all runtimes are in use, call waits for free runtime
+func jsF(i ...interface{}) (interface{}, error) {
+	return jsCall(true, i)
+}
+
+// jsNested call javascript function from script (bafi.try("js", ...)), fails if all runtimes are in use
+func jsNested(i ...interface{}) (interface{}, error) {
+	return jsCall(false, i)
+}
+
+// jsCall call javascript function by jsF or jsNested
+func jsCall(wait bool, i []interface{}) (interface{}, error) {
+	pool := jsRuntimes
+	if pool == nil {
+		return nil, fmt.Errorf("js: no javascript functions loaded (-js, BAFI_JS_PATH or ./js/functions.js)")
+	}
+	if len(i) == 0 {
+		return nil, fmt.Errorf("js: function name must be defined")
+	}
+	vm, err := pool.get(wait)
+	if err != nil {
+		return nil, fmt.Errorf("js: %s", err.Error())
+	}
+	defer pool.put(vm)
+	fn, ok := goja.AssertFunction(vm.Get(toString(i[0])))
+	if !ok {
+		return nil, fmt.Errorf("js: function %v not found", i[0])
+	}
+	args := make([]goja.Value, len(i)-1)
+	for x := range args {
+		value, err := plainValue(i[x+1])
+		if err != nil {
+			return nil, fmt.Errorf("js: input: %s", err.Error())
+		}
+		args[x] = jsValue(vm, value)
+	}
+	stop := setJSTimeout(vm, pool.options.timeout)
+	result, err := fn(goja.Undefined(), args...)
+	stop()
+	if err != nil {
+		return nil, fmt.Errorf("js: %s", jsErrorMessage(err, pool.options.timeout))
+	}
+	if result == nil || goja.IsUndefined(result) || goja.IsNull(result) {
+		return nil, nil
+	}
+	return result.Export(), nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestJSF(t *testing.T) {
+	defer loadJSFunctions(nil, jsOptions{})
+	if _, err := jsF("sum", 1, 2); err == nil || !strings.Contains(err.Error(), "no javascript functions loaded") {
+		t.Errorf("result: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "1_helpers.js"), []byte(`const rate = 2;`), 0644); err != nil {
+		t.Fatalf("writeFile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "2_functions.js"), []byte(`
+function sum(a, b) { return Number(a) + Number(b) * rate; }
+function active(customers) { return customers.filter(c => c.active).map(c => c.name); }
+function info(data) { return {name: bafi.upper(data.name), date: bafi.dateFormat(data.date, "2006-01-02", "02.01.2006"), price: data.price}; }
+function failing() { try { bafi.b64dec("Hello"); } catch (e) { return "caught: " + e.message; } }
+function nothing() {}
+function loop() { while (true) {} }
+`), 0644); err != nil {
+		t.Fatalf("writeFile: %v", err)
+	}
+	if err := loadJSFunctions([]string{dir}, jsOptions{timeout: 100 * time.Millisecond}); err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	if result, err := jsF("sum", "1", 2); err != nil || result != int64(5) {
+		t.Errorf("result: %v %v", result, err)
+	}
+	customers := []map[string]interface{}{{"name": "ACME", "active": true}, {"name": "Globex", "active": false}}
+	if result, err := jsF("active", customers); err != nil || !reflect.DeepEqual(result, []interface{}{"ACME"}) {
+		t.Errorf("result: %v %v", result, err)
+	}
+	result, err := jsF("info", map[string]interface{}{"name": "acme", "date": "2021-05-23", "price": decimal.RequireFromString("0.10")})
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"name": "ACME", "date": "23.05.2021", "price": "0.1"}) {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, _ := jsF("failing"); !strings.Contains(toString(result), "caught: bafi.b64dec: b64dec:") {
+		t.Errorf("result: %v", result)
+	}
+	if result, err := jsF("nothing"); err != nil || result != nil {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := jsF("missing"); err == nil || !strings.Contains(err.Error(), "js: function missing not found") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := jsF("loop"); err == nil || !strings.Contains(err.Error(), "timeout after 100ms") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := jsF("sum", make(chan int)); err == nil || !strings.Contains(err.Error(), "js: input: unsupported type: chan int") {
+		t.Errorf("result: %v", err)
+	}
+	// Runtime is usable after timeout and can be used concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if result, err := jsF("sum", i, 1); err != nil || result != int64(i+2) {
+				t.Errorf("result: %v %v", result, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	broken := writeTestFile(t, "broken.js", `function broken( {`)
+	if err := loadJSFunctions([]string{broken}, jsOptions{}); err == nil || !strings.Contains(err.Error(), "loadJS:") {
+		t.Errorf("result: %v", err)
+	}
+	if err := loadJSFunctions([]string{filepath.Join(dir, "missing.js")}, jsOptions{}); err == nil || !strings.Contains(err.Error(), "loadJS:") {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestJSFiles(t *testing.T) {
+	files := tList{"a.js"}
+	if result := jsFiles(tParams{jsFiles: &files}); !reflect.DeepEqual(result, []string{"a.js"}) {
+		t.Errorf("result: %v", result)
+	}
+	t.Setenv("BAFI_JS_PATH", "./scripts")
+	if result := jsFiles(tParams{}); !reflect.DeepEqual(result, []string{"./scripts"}) {
+		t.Errorf("result: %v", result)
+	}
+	t.Setenv("BAFI_JS_PATH", "")
+	if result := jsFiles(tParams{}); result != nil {
+		t.Errorf("result: %v", result)
+	}
+}
+
+func TestJSSandbox(t *testing.T) {
+	defer loadJSFunctions(nil, jsOptions{})
+	script := writeTestFile(t, "sandbox.js", `
+function functions() { return [typeof bafi.env, typeof bafi.writeFile, typeof bafi.hmac, typeof bafi.lua, bafi.upper("ok")].join(","); }
+function tryEnv() { return bafi.try("env", "HOME"); }
+`)
+	if err := loadJSFunctions([]string{script}, jsOptions{sandbox: true}); err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	if result, err := jsF("functions"); err != nil || result != "undefined,undefined,undefined,undefined,OK" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := jsF("tryEnv"); err == nil || !strings.Contains(err.Error(), "try: function env is not available in sandbox") {
+		t.Errorf("result: %v", err)
+	}
+	if err := loadJSFunctions([]string{script}, jsOptions{}); err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	if result, err := jsF("functions"); err != nil || result != "function,function,function,undefined,OK" {
+		t.Errorf("result: %v %v", result, err)
+	}
+}
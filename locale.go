@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+// tLocale number formatting rules of locale
+type tLocale struct {
+	decimal      string // decimal separator
+	group        string // thousands separator
+	symbolBefore bool   // currency symbol before number ($1.00) or after (1,00 Kč)
+	symbolSpace  bool   // space between number and currency symbol
+}
+
+// tCurrency ISO 4217 currency
+type tCurrency struct {
+	symbol     string
+	minorUnits int32
+}
+
+// locales number formatting by locale (language-REGION), language only is used as fallback
+var locales = map[string]tLocale{
+	"en-us": {decimal: ".", group: ",", symbolBefore: true},
+	"en-gb": {decimal: ".", group: ",", symbolBefore: true},
+	"en":    {decimal: ".", group: ",", symbolBefore: true},
+	"cs-cz": {decimal: ",", group: " ", symbolSpace: true},
+	"cs":    {decimal: ",", group: " ", symbolSpace: true},
+	"sk-sk": {decimal: ",", group: " ", symbolSpace: true},
+	"sk":    {decimal: ",", group: " ", symbolSpace: true},
+	"de-de": {decimal: ",", group: ".", symbolSpace: true},
+	"de-at": {decimal: ",", group: " ", symbolBefore: true, symbolSpace: true},
+	"de-ch": {decimal: ".", group: "'", symbolBefore: true, symbolSpace: true},
+	"de":    {decimal: ",", group: ".", symbolSpace: true},
+	"fr-fr": {decimal: ",", group: " ", symbolSpace: true},
+	"fr":    {decimal: ",", group: " ", symbolSpace: true},
+	"pl-pl": {decimal: ",", group: " ", symbolSpace: true},
+	"pl":    {decimal: ",", group: " ", symbolSpace: true},
+	"it-it": {decimal: ",", group: ".", symbolSpace: true},
+	"it":    {decimal: ",", group: ".", symbolSpace: true},
+	"es-es": {decimal: ",", group: ".", symbolSpace: true},
+	"es":    {decimal: ",", group: ".", symbolSpace: true},
+	"nl-nl": {decimal: ",", group: ".", symbolBefore: true, symbolSpace: true},
+	"nl":    {decimal: ",", group: ".", symbolBefore: true, symbolSpace: true},
+	"hu-hu": {decimal: ",", group: " ", symbolSpace: true},
+	"hu":    {decimal: ",", group: " ", symbolSpace: true},
+	"ja-jp": {decimal: ".", group: ",", symbolBefore: true},
+	"ja":    {decimal: ".", group: ",", symbolBefore: true},
+}
+
+// currencies symbols and minor units (ISO 4217)
+var currencies = map[string]tCurrency{
+	"USD": {symbol: "$", minorUnits: 2},
+	"EUR": {symbol: "€", minorUnits: 2},
+	"CZK": {symbol: "Kč", minorUnits: 2},
+	"GBP": {symbol: "£", minorUnits: 2},
+	"CHF": {symbol: "CHF", minorUnits: 2},
+	"PLN": {symbol: "zł", minorUnits: 2},
+	"HUF": {symbol: "Ft", minorUnits: 2},
+	"SEK": {symbol: "kr", minorUnits: 2},
+	"NOK": {symbol: "kr", minorUnits: 2},
+	"DKK": {symbol: "kr", minorUnits: 2},
+	"CAD": {symbol: "CA$", minorUnits: 2},
+	"AUD": {symbol: "A$", minorUnits: 2},
+	"JPY": {symbol: "¥", minorUnits: 0},
+	"CNY": {symbol: "CN¥", minorUnits: 2},
+	"KWD": {symbol: "KWD", minorUnits: 3},
+	"BHD": {symbol: "BHD", minorUnits: 3},
+}
+
+// getLocale find locale by name (e.g. cs-CZ, cs_CZ, cs), default is en-US
+func getLocale(name string, locale []string) (tLocale, error) {
+	if len(locale) == 0 || locale[0] == "" {
+		return locales["en-us"], nil
+	}
+	key := strings.ToLower(strings.ReplaceAll(locale[0], "_", "-"))
+	if l, ok := locales[key]; ok {
+		return l, nil
+	}
+	language, _, _ := strings.Cut(key, "-")
+	if l, ok := locales[language]; ok {
+		return l, nil
+	}
+	return tLocale{}, fmt.Errorf("%s: unknown locale %s", name, locale[0])
+}
+
+// formatNumber format number by locale with fixed scale (half-up) {{formatNumber .Amount 2 "cs-CZ"}} -> 1 234,56
+func formatNumber(value interface{}, scale int, locale ...string) (string, error) {
+	d, err := decValue("formatNumber", value)
+	if err != nil {
+		return "", err
+	}
+	l, err := getLocale("formatNumber", locale)
+	if err != nil {
+		return "", err
+	}
+	return formatDecimal(d.Round(int32(scale)), int32(scale), l.group, l.decimal, 3), nil
+}
+
+// formatCurrency format amount by locale with currency symbol and ISO 4217 minor units {{formatCurrency .Amount "CZK" "cs-CZ"}} -> 1 234,56 Kč
+func formatCurrency(value interface{}, currency string, locale ...string) (string, error) {
+	d, err := decValue("formatCurrency", value)
+	if err != nil {
+		return "", err
+	}
+	c, ok := currencies[strings.ToUpper(currency)]
+	if !ok {
+		return "", fmt.Errorf("formatCurrency: unknown currency %s", currency)
+	}
+	l, err := getLocale("formatCurrency", locale)
+	if err != nil {
+		return "", err
+	}
+	d = d.Round(c.minorUnits)
+	number := formatDecimal(d.Abs(), c.minorUnits, l.group, l.decimal, 3)
+	space := ""
+	if l.symbolSpace {
+		space = " "
+	}
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+	}
+	if l.symbolBefore {
+		return sign + c.symbol + space + number, nil
+	}
+	return sign + number + space + c.symbol, nil
+}
+
+// parseNumber parse number formatted by locale {{parseNumber "1 234,56 Kč" "cs-CZ"}} -> 1234.56
+// Thousands separators and spaces are ignored, currency symbol or code (e.g. $, Kč, CHF) is accepted at start or end only
+func parseNumber(value string, locale ...string) (decimal.Decimal, error) {
+	l, err := getLocale("parseNumber", locale)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	invalid := fmt.Errorf("parseNumber: invalid number %s", value)
+	number := strings.TrimSpace(value)
+	sign := ""
+	if strings.HasPrefix(number, "-") || strings.HasPrefix(number, "+") { // sign before currency symbol e.g. -$1.00
+		sign, number = number[:1], number[1:]
+	}
+	rest := strings.TrimLeftFunc(number, isCurrencyRune)
+	prefix := number[:len(number)-len(rest)]
+	number = strings.TrimRightFunc(rest, isCurrencyRune)
+	suffix := rest[len(number):]
+	if (prefix != "" && suffix != "") || !isCurrency(prefix) || !isCurrency(suffix) {
+		return decimal.Zero, invalid
+	}
+	number = strings.TrimSpace(number)
+	if sign == "" && (strings.HasPrefix(number, "-") || strings.HasPrefix(number, "+")) { // sign after currency symbol e.g. $-1.00
+		sign, number = number[:1], number[1:]
+	}
+	var b strings.Builder
+	b.WriteString(sign)
+	for _, r := range strings.ReplaceAll(number, l.group, "") {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case string(r) == l.decimal:
+			b.WriteRune('.')
+		case unicode.IsSpace(r), r == '\'':
+			// thousands separators
+		default:
+			return decimal.Zero, invalid
+		}
+	}
+	d, err := decimal.NewFromString(b.String())
+	if err != nil {
+		return decimal.Zero, invalid
+	}
+	return d, nil
+}
+
+// isCurrencyRune letter or currency symbol, part of currency symbol or code
+func isCurrencyRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.Is(unicode.Sc, r)
+}
+
+// isCurrency check if value is empty, currency symbol ($, €), known currency code (USD) or symbol (Kč, CA$)
+func isCurrency(value string) bool {
+	if value == "" {
+		return true
+	}
+	if _, ok := currencies[strings.ToUpper(value)]; ok {
+		return true
+	}
+	for _, c := range currencies {
+		if c.symbol == value {
+			return true
+		}
+	}
+	for _, r := range value {
+		if !unicode.Is(unicode.Sc, r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		scale    int
+		locale   []string
+		expected string
+	}{
+		{1234.5, 2, nil, "1,234.50"},
+		{"1234.567", 2, []string{"cs-CZ"}, "1 234,57"},
+		{decimal.RequireFromString("-1234567.5"), 1, []string{"de-DE"}, "-1.234.567,5"},
+		{1234.5, 0, []string{"de_CH"}, "1'235"},
+		{1234.5, 2, []string{"cs"}, "1 234,50"},
+		{1234.5, 2, []string{"en-AU"}, "1,234.50"},
+	}
+	for _, test := range tests {
+		if result, err := formatNumber(test.value, test.scale, test.locale...); err != nil || result != test.expected {
+			t.Errorf("formatNumber %v %v: %v %v", test.value, test.locale, result, err)
+		}
+	}
+	if _, err := formatNumber(1, 2, "xx-YY"); err == nil || !strings.Contains(err.Error(), "formatNumber: unknown locale xx-YY") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := formatNumber("abc", 2); err == nil || !strings.Contains(err.Error(), "formatNumber: value abc is not a number") {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestFormatCurrency(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		currency string
+		locale   string
+		expected string
+	}{
+		{"1234.56", "CZK", "cs-CZ", "1 234,56 Kč"},
+		{1234.56, "USD", "en-US", "$1,234.56"},
+		{-1234.56, "USD", "en-US", "-$1,234.56"},
+		{1234.56, "EUR", "de-DE", "1.234,56 €"},
+		{1234.5, "eur", "nl", "€ 1.234,50"},
+		{1234.56, "JPY", "ja-JP", "¥1,235"},
+		{"1.2345", "KWD", "en", "KWD1.235"},
+		{decimal.RequireFromString("0.005"), "CZK", "cs", "0,01 Kč"},
+	}
+	for _, test := range tests {
+		if result, err := formatCurrency(test.value, test.currency, test.locale); err != nil || result != test.expected {
+			t.Errorf("formatCurrency %v %s %s: %v %v", test.value, test.currency, test.locale, result, err)
+		}
+	}
+	if _, err := formatCurrency(1, "XXX"); err == nil || !strings.Contains(err.Error(), "formatCurrency: unknown currency XXX") {
+		t.Errorf("result: %v", err)
+	}
+	if err := runt(`{{formatCurrency "1234.56" "CZK" "cs-CZ"}}`, "1 234,56 Kč"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		value    string
+		locale   []string
+		expected string
+	}{
+		{"1 234,56 Kč", []string{"cs-CZ"}, "1234.56"},
+		{"1 234,56", []string{"cs"}, "1234.56"},
+		{"$1,234.56", nil, "1234.56"},
+		{"-1.234.567,5 €", []string{"de-DE"}, "-1234567.5"},
+		{"CHF 1'234.50", []string{"de-CH"}, "1234.5"},
+		{"-$1,234.56", nil, "-1234.56"},
+		{"$-1,234.56", nil, "-1234.56"},
+		{"1234.56 usd", nil, "1234.56"},
+		{"CA$ 10", nil, "10"},
+	}
+	for _, test := range tests {
+		if result, err := parseNumber(test.value, test.locale...); err != nil || result.String() != test.expected {
+			t.Errorf("parseNumber %s %v: %v %v", test.value, test.locale, result, err)
+		}
+	}
+	// Letters are accepted only as currency at start or end
+	for _, value := range []string{"", "Kč", "1.2.3", "12#3", "12O5", "1e3", "12 x", "ABC 12", "$12 USD", "12 Kč EUR", "1-2"} {
+		if _, err := parseNumber(value, "en-US"); err == nil || !strings.Contains(err.Error(), "parseNumber: invalid number") {
+			t.Errorf("parseNumber %s: %v", value, err)
+		}
+	}
+	// Round trip
+	if err := runt(`{{formatNumber (parseNumber "1 234,56 Kč" "cs-CZ" | decMul 2) 2 "de-DE"}}`, "2.469,12"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// defaultLuaFile used if no lua files are defined by -lua or BAFI_LUA_PATH
+const defaultLuaFile = "./lua/functions.lua"
+
+// Sandbox limits of lua state
+const (
+	luaSandboxCallStackSize   = 200        // max depth of lua calls
+	luaSandboxRegistryMaxSize = 256 * 1024 // max size of lua data stack
+)
+
+// luaOptions lua state configuration
+type luaOptions struct {
+	packagePath string        // -luapath: prepended to package.path
+	sandbox     bool          // -luasandbox: only safe libraries (base without file access and code loading, string, table, math), sandbox functions and limited stack
+	timeout     time.Duration // -luatimeout: max duration of single lua call (0 = unlimited)
+}
+
+// luaFiles get list of lua files/directories. Parameter -lua has priority, then BAFI_LUA_PATH environment variable
+// (list separated by OS path list separator) and default ./lua/functions.lua if exists
+func luaFiles(params tParams) []string {
+	var list []string
+	if params.luaFiles != nil {
+		list = *params.luaFiles
+	}
+	return scriptFiles(list, "BAFI_LUA_PATH", defaultLuaFile, "")
+}
+
+// loadLuaFunctions load lua files into new lua state (replaces current one), empty list disables lua.
+// Directories are expanded to *.lua files sorted by name. Directories of loaded files and packagePath are added to package.path
+func loadLuaFunctions(paths []string, options luaOptions) error {
+	luaStates.close()
+	luaStates = nil
+	if len(paths) == 0 {
+		return nil
+	}
+	files, err := expandScriptFiles(paths, ".lua")
+	if err != nil {
+		return fmt.Errorf("loadLua: %s", err.Error())
+	}
+	searchPath := make([]string, 0)
+	if options.packagePath != "" {
+		searchPath = append(searchPath, options.packagePath)
+	}
+	added := make(map[string]bool)
+	for _, file := range files {
+		if dir, err := filepath.Abs(filepath.Dir(file)); err == nil && !added[dir] {
+			added[dir] = true
+			searchPath = append(searchPath, filepath.Join(dir, "?.lua"))
+		}
+	}
+	pool, err := newLuaPool(files, strings.Join(searchPath, ";"), options)
+	if err != nil {
+		return err
+	}
+	luaStates = pool
+	return nil
+}
+
+// newLuaState create lua state with preloaded bafi module. Sandbox opens only safe libraries (no package library,
+// require returns only bafi module), removes functions loading code (dofile, loadfile, load, loadstring) and limits call stack and data stack size
+func newLuaState(sandbox bool) *lua.LState {
+	if !sandbox {
+		state := lua.NewState()
+		state.PreloadModule("bafi", luaBafiModule(sandbox))
+		luaDeterministic(state)
+		return state
+	}
+	state := lua.NewState(lua.Options{
+		SkipOpenLibs:        true,
+		CallStackSize:       luaSandboxCallStackSize,
+		RegistryMaxSize:     luaSandboxRegistryMaxSize,
+		RegistryGrowStep:    1024,
+		MinimizeStackMemory: true,
+	})
+	for _, lib := range []struct {
+		name string
+		fn   lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		state.Push(state.NewFunction(lib.fn))
+		state.Push(lua.LString(lib.name))
+		state.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring"} {
+		state.SetGlobal(name, lua.LNil)
+	}
+	state.SetGlobal("require", state.NewFunction(luaSandboxRequire(map[string]lua.LGFunction{"bafi": luaBafiModule(sandbox)})))
+	luaDeterministic(state)
+	return state
+}
+
+// luaDeterministic replace os.time, os.date (current time) and math.random, math.randomseed by functions using bafi clock
+// and random generator, so scripts follow -now, -seed and -deterministic
+func luaDeterministic(state *lua.LState) {
+	if math, ok := state.GetGlobal(lua.MathLibName).(*lua.LTable); ok {
+		math.RawSetString("random", state.NewFunction(luaRandom))
+		math.RawSetString("randomseed", state.NewFunction(func(L *lua.LState) int { return 0 })) // seed is set by -seed
+	}
+	os, ok := state.GetGlobal(lua.OsLibName).(*lua.LTable) // not available in sandbox
+	if !ok {
+		return
+	}
+	osTime, osDate := os.RawGetString("time"), os.RawGetString("date")
+	os.RawSetString("time", state.NewFunction(func(L *lua.LState) int {
+		if L.GetTop() == 0 || L.Get(1) == lua.LNil {
+			L.Push(lua.LNumber(clock().Unix()))
+			return 1
+		}
+		return luaCallOriginal(L, osTime)
+	}))
+	os.RawSetString("date", state.NewFunction(func(L *lua.LState) int {
+		if L.GetTop() < 2 || L.Get(2) == lua.LNil {
+			format := lua.LString("%c")
+			if L.GetTop() > 0 && L.Get(1) != lua.LNil {
+				format = lua.LString(L.CheckString(1))
+			}
+			L.SetTop(0)
+			L.Push(format)
+			L.Push(lua.LNumber(clock().Unix()))
+		}
+		return luaCallOriginal(L, osDate)
+	}))
+}
+
+// luaCallOriginal call replaced library function with arguments of current call and return its results
+func luaCallOriginal(L *lua.LState, fn lua.LValue) int {
+	top := L.GetTop()
+	L.Insert(fn, 1)
+	L.Call(top, lua.MultRet)
+	return L.GetTop()
+}
+
+// luaRandom math.random using bafi random generator: () float in [0,1), (m) integer in [1,m], (m, n) integer in [m,n]
+func luaRandom(L *lua.LState) int {
+	switch L.GetTop() {
+	case 0:
+		L.Push(lua.LNumber(randomFloat()))
+	case 1:
+		m := L.CheckInt(1)
+		if m < 1 {
+			L.ArgError(1, "interval is empty")
+		}
+		L.Push(lua.LNumber(1 + randomIntn(m)))
+	default:
+		m, n := L.CheckInt(1), L.CheckInt(2)
+		if m > n {
+			L.ArgError(2, "interval is empty")
+		}
+		L.Push(lua.LNumber(m + randomIntn(n-m+1)))
+	}
+	return 1
+}
+
+// luaSandboxRequire require of sandbox, only preloaded modules are available (lua files can't be loaded)
+func luaSandboxRequire(modules map[string]lua.LGFunction) lua.LGFunction {
+	loaded := make(map[string]lua.LValue)
+	return func(L *lua.LState) int {
+		name := L.CheckString(1)
+		if module, ok := loaded[name]; ok {
+			L.Push(module)
+			return 1
+		}
+		loader, ok := modules[name]
+		if !ok {
+			L.RaiseError("module %s is not available in sandbox (only bafi module can be required)", name)
+		}
+		L.Push(L.NewFunction(loader))
+		L.Call(0, 1)
+		loaded[name] = L.Get(-1)
+		return 1
+	}
+}
+
+// luaBafiModule bafi module with template functions local bafi = require "bafi"; bafi.dateFormat(date, "2006-01-02", "02.01.2006")
+// Function errors are raised as lua errors (use pcall to handle them). lua function is not available, sandbox gets only sandbox functions (no file or environment access)
+func luaBafiModule(sandbox bool) lua.LGFunction {
+	return func(L *lua.LState) int {
+		module := L.NewTable()
+		for name, fn := range scriptFunctions(sandbox) {
+			module.RawSetString(name, L.NewFunction(luaBafiFunction(name, fn)))
+		}
+		L.Push(module)
+		return 1
+	}
+}
+
+// luaBafiFunction wrap template function to lua function, arguments and result are converted between lua and Go values
+func luaBafiFunction(name string, fn interface{}) lua.LGFunction {
+	return func(L *lua.LState) int {
+		args := make([]interface{}, L.GetTop())
+		for i := range args {
+			value, err := fromLuaValue(L.Get(i+1), make(map[*lua.LTable]bool))
+			if err != nil {
+				L.RaiseError("bafi.%s: arg %d: %s", name, i, err.Error())
+			}
+			args[i] = value
+		}
+		result, err := callFunction(fn, args)
+		if err != nil {
+			L.RaiseError("bafi.%s: %s", name, err.Error())
+		}
+		value, err := toLuaValue(L, result)
+		if err != nil {
+			L.RaiseError("bafi.%s: %s", name, err.Error())
+		}
+		L.Push(value)
+		return 1
+	}
+}
+
+// setLuaTimeout limit duration of lua execution, returned function must be called after execution
+func setLuaTimeout(state *lua.LState, timeout time.Duration) context.CancelFunc {
+	if timeout <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	state.SetContext(ctx)
+	return func() {
+		state.RemoveContext()
+		cancel()
+	}
+}
+
+// luaErrorMessage replace context error by readable timeout message
+func luaErrorMessage(err error, timeout time.Duration) string {
+	if timeout > 0 && strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		return fmt.Sprintf("timeout after %s (-luatimeout)", timeout)
+	}
+	return err.Error()
+}
+
+// luaF Call LUA function {{lua "functionName" input1 input2 input3 ...}}
+// Inputs are passed as native lua values (tables, numbers, strings, booleans, nil),
+// returned table/number/string/boolean is converted back to map, array, int64/float64, string, bool.
+// If all lua states are in use, call waits for free state
+func luaF(i ...interface{}) (interface{}, error) {
+	return luaCall(true, i)
+}
+
+// luaNested call lua function from script (bafi.try("lua", ...)), fails if all lua states are in use
+func luaNested(i ...interface{}) (interface{}, error) {
+	return luaCall(false, i)
+}
+
+// luaCall call lua function by luaF or luaNested
+func luaCall(wait bool, i []interface{}) (interface{}, error) {
+	pool := luaStates
+	if pool == nil {
+		return nil, fmt.Errorf("lua: no lua functions loaded (-lua, BAFI_LUA_PATH or ./lua/functions.lua)")
+	}
+	if len(i) == 0 {
+		return nil, fmt.Errorf("lua: function name must be defined")
+	}
+	state, err := pool.get(wait)
+	if err != nil {
+		return nil, fmt.Errorf("lua: %s", err.Error())
+	}
+	defer pool.put(state)
+	args := make([]lua.LValue, len(i)-1)
+	for x := range args {
+		value, err := toLuaValue(state, i[x+1])
+		if err != nil {
+			return nil, fmt.Errorf("lua: input: %s", err.Error())
+		}
+		args[x] = value
+	}
+	cancel := setLuaTimeout(state, pool.options.timeout)
+	err = state.CallByParam(lua.P{Fn: state.GetGlobal(toString(i[0])), NRet: 1, Protect: true}, args...)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("lua: %s", luaErrorMessage(err, pool.options.timeout))
+	}
+	result := state.Get(-1)
+	state.Pop(1)
+	value, err := fromLuaValue(result, make(map[*lua.LTable]bool))
+	if err != nil {
+		return nil, fmt.Errorf("lua: function %v: %s", i[0], err.Error())
+	}
+	return value, nil
+}
+
+// luaTemplate lua template function, in non-strict mode error message is returned instead of error (legacy behavior)
+func luaTemplate(i ...interface{}) (interface{}, error) {
+	result, err := luaF(i...)
+	if err != nil {
+		return strictError[interface{}](fmt.Sprintf("luaError: %s\r\n", strings.TrimPrefix(err.Error(), "lua: ")), err)
+	}
+	return result, nil
+}
+
+// luaPreHook call lua function with whole mapped input data (-luapre), returned value replaces input data
+func luaPreHook(name string, mapData interface{}) (interface{}, error) {
+	if name == "" {
+		return mapData, nil
+	}
+	result, err := luaF(name, mapData)
+	if err != nil {
+		return nil, fmt.Errorf("luaPre: %s", err.Error())
+	}
+	if result == nil {
+		return nil, fmt.Errorf("luaPre: function %s must return data", name)
+	}
+	return result, nil
+}
+
+// luaPostHook call lua function with rendered output (-luapost), returned string replaces output
+func luaPostHook(name string, output []byte) ([]byte, error) {
+	if name == "" {
+		return output, nil
+	}
+	result, err := luaF(name, string(output))
+	if err != nil {
+		return nil, fmt.Errorf("luaPost: %s", err.Error())
+	}
+	str, ok := result.(string)
+	if !ok {
+		return nil, fmt.Errorf("luaPost: function %s must return string, got %T", name, result)
+	}
+	return []byte(str), nil
+}
+
+// toLuaValue convert Go value to lua value (by plainValue). Maps are converted to tables, arrays to tables indexed from 1
+func toLuaValue(L *lua.LState, v interface{}) (lua.LValue, error) {
+	if value, ok := v.(lua.LValue); ok {
+		return value, nil
+	}
+	plain, err := plainValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return luaValue(L, plain), nil
+}
+
+// luaValue convert plain value (result of plainValue) to lua value. Map keys are inserted in sorted order,
+// so pairs() iterates in the same order on every run
+func luaValue(L *lua.LState, v interface{}) lua.LValue {
+	switch value := v.(type) {
+	case string:
+		return lua.LString(value)
+	case bool:
+		return lua.LBool(value)
+	case int64:
+		return lua.LNumber(value)
+	case float64:
+		return lua.LNumber(value)
+	case map[string]interface{}:
+		table := L.CreateTable(0, len(value))
+		for _, key := range sortedKeys(value) {
+			table.RawSetString(key, luaValue(L, value[key]))
+		}
+		return table
+	case []interface{}:
+		table := L.CreateTable(len(value), 0)
+		for x, item := range value {
+			table.RawSetInt(x+1, luaValue(L, item))
+		}
+		return table
+	}
+	return lua.LNil
+}
+
+// fromLuaValue convert lua value to Go value. Whole numbers are converted to int64, other numbers to float64.
+// Table with keys 1..n is converted to array (nil items are kept as nil e.g. {nil, 1}), other tables to map[string]interface{}
+func fromLuaValue(v lua.LValue, visited map[*lua.LTable]bool) (interface{}, error) {
+	switch value := v.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case lua.LBool:
+		return bool(value), nil
+	case lua.LNumber:
+		return luaNumber(value), nil
+	case lua.LString:
+		return string(value), nil
+	case *lua.LTable:
+		if visited[value] {
+			return nil, fmt.Errorf("cyclic table can't be returned")
+		}
+		visited[value] = true
+		defer delete(visited, value)
+		count := 0
+		value.ForEach(func(lua.LValue, lua.LValue) { count++ })
+		if length := value.MaxN(); luaArrayItems(value, length) == count {
+			array := make([]interface{}, length)
+			for x := 1; x <= length; x++ {
+				item, err := fromLuaValue(value.RawGetInt(x), visited)
+				if err != nil {
+					return nil, err
+				}
+				array[x-1] = item
+			}
+			return array, nil
+		}
+		mapData := make(map[string]interface{}, count)
+		var err error
+		value.ForEach(func(key lua.LValue, item lua.LValue) {
+			if err == nil {
+				mapData[key.String()], err = fromLuaValue(item, visited)
+			}
+		})
+		return mapData, err
+	default:
+		return nil, fmt.Errorf("unsupported return type %s", v.Type().String())
+	}
+}
+
+// luaNumber convert lua number to int64 if it is whole number (1000000 instead of 1e+06), otherwise to float64
+// with 14 significant digits like lua tostring (0.1 + 0.2 is 0.3 instead of 0.30000000000000004)
+func luaNumber(n lua.LNumber) interface{} {
+	value := float64(n)
+	if value == math.Trunc(value) && value >= math.MinInt64 && value < math.MaxInt64 {
+		return int64(value)
+	}
+	if rounded, err := strconv.ParseFloat(strconv.FormatFloat(value, 'g', 14, 64), 64); err == nil {
+		return rounded
+	}
+	return value
+}
+
+// luaArrayItems number of non-nil items of table array part up to length
+func luaArrayItems(table *lua.LTable, length int) int {
+	items := 0
+	for x := 1; x <= length; x++ {
+		if table.RawGetInt(x) != lua.LNil {
+			items++
+		}
+	}
+	return items
+}
@@ -1,11 +1,9 @@
-json = require './lua/json'
-
-function sum(incomingData) 
-    dataTable = json.decode(incomingData)
-    return tostring(tonumber(dataTable[1]) + tonumber(dataTable[2]))
+-- Template inputs are passed as native lua values (tables, numbers, strings, booleans, nil)
+-- {{lua "sum" .val1 .val2}}
+function sum(a, b)
+    return tonumber(a) + tonumber(b)
 end
 
-function mul(incomingData) 
-    dataTable = json.decode(incomingData)
-    return tostring(tonumber(dataTable[1]) * tonumber(dataTable[2]))
-end
\ No newline at end of file
+function mul(a, b)
+    return tonumber(a) * tonumber(b)
+end
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestLuaF(t *testing.T) {
+	if err := loadLuaFunctions([]string{"./lua/functions.lua"}, luaOptions{}); err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	if result, _ := luaF("sum", "5", "5"); result != int64(10) {
+		t.Errorf("result: %v", result)
+	}
+	if _, err := luaF("Unknown", "5", "5"); err == nil || !strings.Contains(err.Error(), `attempt to call a non-function object`) {
+		t.Errorf("result: %v", err)
+	}
+	testData := make(map[string]interface{})
+	testData["Hello"] = make(chan int)
+	if _, err := luaF("sum", testData); err == nil || !strings.Contains(err.Error(), "lua: input: unsupported type: chan int") {
+		t.Errorf("result: %v", err)
+	}
+	// Template function returns error message in non-strict mode
+	if result, err := renderCall("lua", "Unknown", "5"); err != nil || !strings.HasPrefix(toString(result), "luaError: ") {
+		t.Errorf("result: %v, %v", result, err)
+	}
+	if _, err := luaTemplate("Unknown", "5"); err == nil || !strings.Contains(err.Error(), `attempt to call a non-function object`) {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestLuaValues(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+	input := map[string]interface{}{"name": "ACME", "count": 2, "ok": true, "items": []interface{}{"a", 1.5, nil}, "none": nil}
+	value, err := toLuaValue(L, input)
+	if err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	table := value.(*lua.LTable)
+	if table.RawGetString("name") != lua.LString("ACME") || table.RawGetString("count") != lua.LNumber(2) || table.RawGetString("ok") != lua.LTrue {
+		t.Errorf("result: %v", table)
+	}
+	if items := table.RawGetString("items").(*lua.LTable); items.RawGetInt(1) != lua.LString("a") || items.RawGetInt(2) != lua.LNumber(1.5) {
+		t.Errorf("result: %v", items)
+	}
+	result, err := fromLuaValue(value, make(map[*lua.LTable]bool))
+	if err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"name": "ACME", "count": int64(2), "ok": true, "items": []interface{}{"a", 1.5}}) {
+		t.Errorf("result: %v", result)
+	}
+	if err := L.DoString(`cyclic = {}; cyclic.self = cyclic; empty = {}; mixed = {1, 2, x = "y"}; holes = {nil, 1}; holes[4] = 2; sparse = {}; sparse[2] = 1; big = 1000 * 1000; fraction = 0.1 + 0.2`); err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	if _, err := fromLuaValue(L.GetGlobal("cyclic"), make(map[*lua.LTable]bool)); err == nil {
+		t.Errorf("result: expected error")
+	}
+	if result, _ := fromLuaValue(L.GetGlobal("empty"), make(map[*lua.LTable]bool)); !reflect.DeepEqual(result, []interface{}{}) {
+		t.Errorf("result: %v", result)
+	}
+	if result, _ := fromLuaValue(L.GetGlobal("mixed"), make(map[*lua.LTable]bool)); !reflect.DeepEqual(result, map[string]interface{}{"1": int64(1), "2": int64(2), "x": "y"}) {
+		t.Errorf("result: %v", result)
+	}
+	// Arrays with nil items are kept as arrays
+	if result, _ := fromLuaValue(L.GetGlobal("holes"), make(map[*lua.LTable]bool)); !reflect.DeepEqual(result, []interface{}{nil, int64(1), nil, int64(2)}) {
+		t.Errorf("result: %v", result)
+	}
+	if result, _ := fromLuaValue(L.GetGlobal("sparse"), make(map[*lua.LTable]bool)); !reflect.DeepEqual(result, []interface{}{nil, int64(1)}) {
+		t.Errorf("result: %v", result)
+	}
+	input = map[string]interface{}{"a": []interface{}{nil, 1.0}}
+	value, _ = toLuaValue(L, input)
+	if result, _ := fromLuaValue(value, make(map[*lua.LTable]bool)); !reflect.DeepEqual(result, map[string]interface{}{"a": []interface{}{nil, int64(1)}}) {
+		t.Errorf("result: %v", result)
+	}
+	// Whole numbers are integers (rendered as 1000000 instead of 1e+06)
+	if result, _ := fromLuaValue(L.GetGlobal("big"), nil); result != int64(1000000) || toString(result) != "1000000" {
+		t.Errorf("result: %v", result)
+	}
+	if result, _ := fromLuaValue(L.GetGlobal("fraction"), nil); result != 0.3 || toString(result) != "0.3" {
+		t.Errorf("result: %v", result)
+	}
+	if _, err := fromLuaValue(L.NewFunction(func(*lua.LState) int { return 0 }), nil); err == nil {
+		t.Errorf("result: expected error")
+	}
+}
+
+func TestLuaFiles(t *testing.T) {
+	files := tList{"a.lua", "./scripts"}
+	if result := luaFiles(tParams{luaFiles: &files}); !reflect.DeepEqual(result, []string(files)) {
+		t.Errorf("result: %v", result)
+	}
+	t.Setenv("BAFI_LUA_PATH", "one.lua"+string(os.PathListSeparator)+"./lib")
+	if result := luaFiles(tParams{luaFiles: &tList{}}); !reflect.DeepEqual(result, []string{"one.lua", "./lib"}) {
+		t.Errorf("result: %v", result)
+	}
+	t.Setenv("BAFI_LUA_PATH", "")
+	if result := luaFiles(tParams{}); !reflect.DeepEqual(result, []string{defaultLuaFile}) {
+		t.Errorf("result: %v", result)
+	}
+}
+
+func TestLoadLuaFunctions(t *testing.T) {
+	defer loadLuaFunctions(nil, luaOptions{})
+	dir := t.TempDir()
+	scripts := filepath.Join(dir, "scripts")
+	lib := filepath.Join(dir, "lib")
+	for _, path := range []string{scripts, lib} {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(scripts, "1_helpers.lua"): `helpers = require "helpers"`,
+		filepath.Join(scripts, "2_main.lua"):    `function greet(name) return helpers.prefix .. name .. suffix() end`,
+		filepath.Join(scripts, "helpers.lua"):   `return {prefix = "Hello "}`,
+		filepath.Join(scripts, "README.md"):     `not lua`,
+		filepath.Join(lib, "extra.lua"):         `return "!"`,
+		filepath.Join(dir, "suffix.lua"):        `function suffix() return require "extra" end`,
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writeFile: %v", err)
+		}
+	}
+	if err := loadLuaFunctions([]string{filepath.Join(dir, "suffix.lua"), scripts}, luaOptions{packagePath: filepath.Join(lib, "?.lua")}); err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	if result, err := luaF("greet", "World"); err != nil || result != "Hello World!" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if err := loadLuaFunctions([]string{filepath.Join(dir, "missing.lua")}, luaOptions{}); err == nil || !strings.Contains(err.Error(), "loadLua:") {
+		t.Errorf("result: %v", err)
+	}
+	if luaStates != nil {
+		t.Errorf("result: previous lua state should be closed")
+	}
+	broken := filepath.Join(dir, "broken.lua")
+	if err := os.WriteFile(broken, []byte(`function broken(`), 0644); err != nil {
+		t.Fatalf("writeFile: %v", err)
+	}
+	if err := loadLuaFunctions([]string{broken}, luaOptions{}); err == nil || !strings.Contains(err.Error(), "loadLua:") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := luaF("greet", "World"); err == nil || !strings.Contains(err.Error(), "no lua functions loaded") {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestLuaSandbox(t *testing.T) {
+	defer loadLuaFunctions(nil, luaOptions{})
+	script := writeTestFile(t, "sandbox.lua", `
+function libs() return tostring(os) .. "," .. tostring(io) .. "," .. tostring(dofile) .. "," .. string.upper("ok") .. "," .. math.floor(1.5) end
+function loop() while true do end end
+function recurse(n) return 1 + recurse(n + 1) end
+function grow() return unpack({}, 1, 1000000) end
+function loaders() return tostring(package) .. "," .. tostring(load) .. "," .. tostring(loadstring) .. "," .. tostring(loadfile) end
+function requireFile() return require "os" end
+function bafiModule() local bafi = require "bafi"; return tostring(bafi.env) .. "," .. tostring(bafi.writeFile) .. "," .. bafi.upper("ok") end
+function tryEnv() return require("bafi").try("env", "HOME") end
+`)
+	if err := loadLuaFunctions([]string{script}, luaOptions{sandbox: true, timeout: 200 * time.Millisecond}); err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	if result, err := luaF("libs"); err != nil || result != "nil,nil,nil,OK,1" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := luaF("loop"); err == nil || !strings.Contains(err.Error(), "timeout after 200ms") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := luaF("recurse", 1); err == nil || !strings.Contains(err.Error(), "stack overflow") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := luaF("grow"); err == nil || !strings.Contains(err.Error(), "registry overflow") {
+		t.Errorf("result: expected error")
+	}
+	if result, err := luaF("loaders"); err != nil || result != "nil,nil,nil,nil" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := luaF("requireFile"); err == nil || !strings.Contains(err.Error(), "module os is not available in sandbox") {
+		t.Errorf("result: %v", err)
+	}
+	if result, err := luaF("bafiModule"); err != nil || result != "nil,nil,OK" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := luaF("tryEnv"); err == nil || !strings.Contains(err.Error(), "try: function env is not available in sandbox") {
+		t.Errorf("result: %v", err)
+	}
+	// State is usable after violations
+	if result, err := luaF("libs"); err != nil || result != "nil,nil,nil,OK,1" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	infinite := writeTestFile(t, "infinite.lua", `while true do end`)
+	if err := loadLuaFunctions([]string{infinite}, luaOptions{timeout: 100 * time.Millisecond}); err == nil || !strings.Contains(err.Error(), "loadLua: timeout") {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestLuaHooks(t *testing.T) {
+	defer loadLuaFunctions(nil, luaOptions{})
+	script := writeTestFile(t, "hooks.lua", `
+function prepare(data)
+    local active = {}
+    for _, customer in ipairs(data.customers) do
+        if customer.active then table.insert(active, customer.name) end
+    end
+    data.active = active
+    data.customers = nil
+    return data
+end
+function cleanup(output) return (string.gsub(output, "%s+$", "")) end
+function nothing(data) end
+`)
+	if err := loadLuaFunctions([]string{script}, luaOptions{}); err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	input := map[string]interface{}{"customers": []interface{}{
+		map[string]interface{}{"name": "ACME", "active": true},
+		map[string]interface{}{"name": "Globex", "active": false},
+	}}
+	result, err := luaPreHook("prepare", input)
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"active": []interface{}{"ACME"}}) {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, _ := luaPreHook("", input); !reflect.DeepEqual(result, input) {
+		t.Errorf("result: %v", result)
+	}
+	if _, err := luaPreHook("nothing", input); err == nil || !strings.Contains(err.Error(), "luaPre: function nothing must return data") {
+		t.Errorf("result: %v", err)
+	}
+	if output, err := luaPostHook("cleanup", []byte("Hello \r\n\n")); err != nil || string(output) != "Hello" {
+		t.Errorf("result: %q %v", output, err)
+	}
+	if _, err := luaPostHook("nothing", []byte("Hello")); err == nil || !strings.Contains(err.Error(), "luaPost: function nothing must return string") {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestLuaBafiModule(t *testing.T) {
+	defer loadLuaFunctions(nil, luaOptions{})
+	script := writeTestFile(t, "bafi.lua", `
+local bafi = require "bafi"
+function helpers(date)
+    return {
+        date = bafi.dateFormat(date, "2006-01-02", "02.01.2006"),
+        decimal = bafi.toDecimalString("0.1") + 0,
+        round = bafi.round(3.14159, 2),
+        b64 = bafi.b64enc("Hello"),
+        upper = bafi.upper("bafi"),
+        match = bafi.regexMatch("^[0-9]+$", "123"),
+        default = bafi.default("n/a", ""),
+        lua = tostring(bafi.lua),
+    }
+end
+function failing()
+    local ok, err = pcall(bafi.b64dec, "Hello")
+    return tostring(ok) .. " " .. err
+end
+function sandboxed() return tostring(bafi.writeFile) .. "," .. tostring(bafi.env) end
+function nested(depth) if depth == 0 then return "done" end return bafi.try("lua", "nested", depth - 1) end
+`)
+	if err := loadLuaFunctions([]string{script}, luaOptions{}); err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	result, err := luaF("helpers", "2021-05-23")
+	expected := map[string]interface{}{"date": "23.05.2021", "decimal": 0.1, "round": 3.14, "b64": "SGVsbG8=", "upper": "BAFI", "match": true, "default": "n/a", "lua": "nil"}
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, _ := luaF("failing"); !strings.Contains(toString(result), "false") || !strings.Contains(toString(result), "bafi.b64dec: b64dec:") {
+		t.Errorf("result: %v", result)
+	}
+	if result, _ := luaF("sandboxed"); !strings.Contains(toString(result), "function") {
+		t.Errorf("result: %v", result)
+	}
+	// Nested calls use own state, too deep nesting fails instead of waiting for state held by caller
+	if result, err := luaF("nested", 3); err != nil || result != "done" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := luaF("nested", maxScriptEngines); err != nil || result != nil {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if err := loadLuaFunctions([]string{script}, luaOptions{sandbox: true}); err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	if result, _ := luaF("sandboxed"); result != "nil,nil" {
+		t.Errorf("result: %v", result)
+	}
+}
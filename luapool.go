@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// luaPool pool of lua states with loaded scripts (lua.LState is not goroutine-safe, each call checks out own state)
+type luaPool struct {
+	*scriptPool[*lua.LState]
+	files      []string   // lua files loaded to each state
+	searchPath string     // package.path of each state
+	options    luaOptions // state configuration
+}
+
+var luaStates *luaPool // nil if no lua files are loaded
+
+// newLuaPool create pool and first state, so script errors are reported before rendering
+func newLuaPool(files []string, searchPath string, options luaOptions) (*luaPool, error) {
+	pool := &luaPool{files: files, searchPath: searchPath, options: options}
+	states, err := newScriptPool("lua states", pool.newState, (*lua.LState).Close)
+	if err != nil {
+		return nil, err
+	}
+	pool.scriptPool = states
+	return pool, nil
+}
+
+// newState create lua state and load scripts
+func (p *luaPool) newState() (*lua.LState, error) {
+	state := newLuaState(p.options.sandbox)
+	if pkg, ok := state.GetGlobal("package").(*lua.LTable); ok && p.searchPath != "" {
+		pkg.RawSetString("path", lua.LString(p.searchPath+";"+lua.LVAsString(pkg.RawGetString("path"))))
+	}
+	for _, file := range p.files {
+		cancel := setLuaTimeout(state, p.options.timeout)
+		err := state.DoFile(file)
+		cancel()
+		if err != nil {
+			state.Close()
+			return nil, fmt.Errorf("loadLua: %s", luaErrorMessage(err, p.options.timeout))
+		}
+	}
+	return state, nil
+}
+
+// close all states of pool
+func (p *luaPool) close() {
+	if p == nil {
+		return
+	}
+	p.scriptPool.close()
+}
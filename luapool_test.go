@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestLuaPool(t *testing.T) {
+	defer loadLuaFunctions(nil, luaOptions{})
+	script := writeTestFile(t, "pool.lua", `
+local bafi = require "bafi"
+function greet(name, i) return bafi.upper(name) .. i end
+`)
+	if err := loadLuaFunctions([]string{script}, luaOptions{}); err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	var wg sync.WaitGroup
+	// More goroutines than states, calls wait for free state
+	errors := make(chan error, 4*maxScriptEngines)
+	for i := 0; i < 4*maxScriptEngines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := luaF("greet", "bafi", i)
+			if err != nil || result != fmt.Sprintf("BAFI%d", i) {
+				errors <- fmt.Errorf("result: %v %v", result, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errors)
+	for err := range errors {
+		t.Error(err)
+	}
+	if idle, states := len(luaStates.idle), len(luaStates.engines); idle != states || states < 1 || states > maxScriptEngines {
+		t.Errorf("result: idle %d, states %d", idle, states)
+	}
+	// Sequential calls reuse the same state
+	pool := luaStates
+	state, _ := pool.get(true)
+	pool.put(state)
+	if next, _ := pool.get(true); next != state {
+		t.Errorf("result: expected reused state")
+	}
+	// Pool is limited to maxScriptEngines states, nested call fails, top-level call waits for returned state
+	checkedOut := []*lua.LState{state}
+	for {
+		state, err := pool.get(false)
+		if err != nil {
+			if !strings.Contains(err.Error(), "lua states are in use") || len(pool.engines) != maxScriptEngines {
+				t.Errorf("result: %v, states %d", err, len(pool.engines))
+			}
+			break
+		}
+		checkedOut = append(checkedOut, state)
+	}
+	waiting := make(chan *lua.LState)
+	go func() {
+		state, _ := pool.get(true)
+		waiting <- state
+	}()
+	pool.put(checkedOut[0])
+	if state := <-waiting; state != checkedOut[0] {
+		t.Errorf("result: expected returned state")
+	}
+	for _, state := range checkedOut {
+		pool.put(state)
+	}
+	pool.close()
+	if len(pool.engines) != 0 || len(pool.idle) != 0 {
+		t.Errorf("result: states not closed")
+	}
+	luaStates = nil
+	var empty *luaPool
+	empty.close()
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/csv"
 	"encoding/hex"
 	"encoding/json"
@@ -10,7 +11,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
@@ -20,19 +20,22 @@ import (
 	"github.com/clbanning/mxj/v2"
 	"github.com/mmalcek/mt940"
 	"github.com/sashabaranov/go-openai"
-	lua "github.com/yuin/gopher-lua"
 	"go.mongodb.org/mongo-driver/bson"
 	"gopkg.in/yaml.v3"
 )
 
 const version = "1.2.1"
 
+// maxIncludeDepth max nesting of include calls (recursive include ends with error instead of stack overflow)
+const maxIncludeDepth = 100
+
 var (
-	luaData *lua.LState
+	strictMode bool // -strict: missing keys and function errors abort rendering
 )
 
 type tParams struct {
 	inputFile      *string
+	inputList      []map[string]interface{} // list of input files defined inline (job config), same as "?" list file
 	outputFile     *string
 	textTemplate   *string
 	inputFormat    *string
@@ -42,20 +45,86 @@ type tParams struct {
 	chatGPTkey     *string
 	chatGPTmodel   *string
 	chatGPTquery   *string
+	schemaFile     *string
+	outputFormat   *string
+	outputSchema   *string
+	strict         *bool
+	templateDir    *string
+	outputDir      *string
+	outputMode     *string
+	manifestFile   *string
+	outputPerm     *string
+	outputMkdir    *bool
+	onFail         *string
+	vars           tVars
+	envAllow       *string
+	keys           *tList
+	luaFiles       *tList
+	luaPath        *string
+	luaSandbox     *bool
+	luaTimeout     *time.Duration
+	luaPre         *string
+	luaPost        *string
+	jsFiles        *tList
+	jsTimeout      *time.Duration
+	jsSandbox      *bool
+	query          *string
+	seed           *int64
+	now            *string
+	uuidNamespace  *string
+	deterministic  *bool
 }
 
-func init() {
-	rand.Seed(time.Now().UTC().UnixNano())
-	if _, err := os.Stat("./lua/functions.lua"); !os.IsNotExist(err) {
-		luaData = lua.NewState()
-		if err := luaData.DoFile("./lua/functions.lua"); err != nil {
-			log.Fatal("loadLuaFunctions", err.Error())
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		err := runJobs(os.Args[2:])
+		luaStates.close()
+		if err != nil {
+			log.Fatal(err.Error())
 		}
+		return
 	}
-}
-
-func main() {
+	vars := tVars{}
+	keys := &tList{}
+	luaFiles := &tList{}
+	jsFiles := &tList{}
+	flag.Var(jsFiles, "js", `javascript file or directory (all *.js files) with custom functions, can be repeated (loaded in order)
+ -if not defined BAFI_JS_PATH environment variable (list of files/directories) or ./js/functions.js is used`)
+	flag.Var(luaFiles, "lua", `lua file or directory (all *.lua files) with custom functions, can be repeated (loaded in order)
+ -if not defined BAFI_LUA_PATH environment variable (list of files/directories) or ./lua/functions.lua is used`)
+	flag.Var(vars, "var", `template variable key=value, can be repeated
+ -e.g. -var company=ACME -var url=https://example.com used in template as {{var "company"}} or {{(vars).url}}`)
+	flag.Var(keys, "keys", `key file or directory readable by hmac ("file:key.txt") and jws functions, can be repeated
+ -e.g. -keys ./keys/private.pem -keys /etc/bafi/keys (key files are not readable if not defined)`)
 	params := tParams{
+		vars:     vars,
+		keys:     keys,
+		luaFiles: luaFiles,
+		jsFiles:  jsFiles,
+		query: flag.String("q", "", `JSONPath query applied to input data, result is used as template data
+ -e.g. -q "$.TOP_LEVEL.DATA_LINE[?(@.val1 > 10)]"
+ -if template is not defined result is written as JSON`),
+		seed: flag.Int64("seed", 0, `random seed used by randInt, lua math.random and javascript Math.random e.g. -seed 42 (default random)
+ -same seed generates the same sequence of numbers`),
+		now: flag.String("now", "", `fixed current date/time used by now, nowTZ, lua os.time and javascript Date, ISO-8601 e.g. -now 2024-01-31T12:00:00Z`),
+		uuidNamespace: flag.String("uuidns", "", `generate UUIDv5 by uuid function from namespace, input data hash and sequence number instead of random UUID
+ -namespace can be UUID, dns, url, oid, x500 or any text e.g. -uuidns invoices`),
+		deterministic: flag.Bool("deterministic", false, `reproducible output: the same input always generates the same output
+ -fixed random seed (-seed or 0), clock (-now or 1970-01-01T00:00:00Z) and UUIDv5 (-uuidns or default namespace)`),
+		jsTimeout: flag.Duration("jstimeout", 0, "max duration of single javascript call e.g. -jstimeout 5s (default unlimited)"),
+		jsSandbox: flag.Bool("jssandbox", false, `run javascript in sandbox
+ -bafi object contains only functions without file or environment access (same as -luasandbox)`),
+		luaPath: flag.String("luapath", "", `lua package.path for require (prepended to default) e.g. -luapath "./lib/?.lua"
+ -directories of loaded lua files are added automatically`),
+		luaSandbox: flag.Bool("luasandbox", false, `run lua in sandbox
+ -only safe libraries are available (base without dofile/loadfile/load/loadstring, string, table, math), no os, io, package
+ -require returns only bafi module with functions without file or environment access
+ -call stack and data stack size are limited`),
+		luaPre: flag.String("luapre", "", `lua function called with whole mapped input data before template is applied
+ -function must return data (table) used by template e.g. -luapre prepare`),
+		luaPost: flag.String("luapost", "", `lua function called with rendered output before it's written
+ -function must return string e.g. -luapost cleanup`),
+		luaTimeout: flag.Duration("luatimeout", 0, "max duration of single lua call e.g. -luatimeout 5s (default unlimited)"),
 		inputFile: flag.String("i", "", `input file 
  -if not defined read from stdin (pipe mode)
  -if prefixed with "?" app will expect yaml file with multiple files description. `),
@@ -70,35 +139,96 @@ func main() {
 		chatGPTkey:     flag.String("gk", "", "OpenAI API key"),
 		chatGPTmodel:   flag.String("gm", "gpt35", "OpenAI GPT-3 model (gpt35, gpt4)"),
 		chatGPTquery:   flag.String("gq", "", "OpenAI query"),
+		schemaFile: flag.String("schema", "", `validate input before rendering
+ -JSON Schema (draft 2020-12) for mapped data of any input format e.g. -schema schema.json
+ -XSD for xml input e.g. -schema schema.xsd`),
+		outputFormat: flag.String("of", "", `output format: json, yaml, xml
+ -if defined rendered output is parsed back and must be valid before it's written
+ -if not defined but -os is set format is detected by output file extension`),
+		outputSchema: flag.String("os", "", "validate rendered output against JSON Schema or XSD e.g. -os schema.json"),
+		templateDir: flag.String("tdir", "", `template library directory
+ -all *.tmpl files (including subdirectories) are parsed into the same template set
+ -templates can be used by {{template "header.tmpl" .}} or {{include "header.tmpl" . | upper}}`),
+		outputDir: flag.String("odir", "", `output directory for files written by writeFile template function
+ -if not defined directory of output file (-o) or current directory is used`),
+		outputMode:   flag.String("omode", "overwrite", "writeFile policy for existing files: overwrite, append, skip, fail"),
+		manifestFile: flag.String("manifest", "", "write JSON list of files written by writeFile template function e.g. -manifest manifest.json"),
+		outputPerm: flag.String("perm", "", `output file permissions (octal) e.g. -perm 0600
+ -if not defined new files are created with 0644 and existing files keep their permissions`),
+		outputMkdir: flag.Bool("mkdir", false, "create missing parent directories of output file"),
+		onFail: flag.String("onfail", "keep", `what to do with existing output file (-o) if processing fails
+ -keep: previous file is preserved (output is always written to temp file and renamed on success)
+ -remove: previous file is removed so stale data can't be processed`),
+		envAllow: flag.String("envallow", "", `comma separated list of environment variables accessible by env template function
+ -e.g. -envallow "TENANT,BAFI_*" (if not defined all variables are accessible)`),
+		strict: flag.Bool("strict", false, `strict mode
+ -missing keys in template (e.g. typo {{.TOP_LEVEL.DATA_LIEN}}) fail instead of printing "<no value>"
+ -template functions fail instead of returning fallback values or error messages`),
 	}
 	flag.Parse()
+	seedSet := false // -seed 0 is valid seed, nil means random seed
+	flag.Visit(func(f *flag.Flag) { seedSet = seedSet || f.Name == "seed" })
+	if !seedSet {
+		params.seed = nil
+	}
 
 	if err := processTemplate(params); err != nil {
 		log.Fatal(err.Error())
 	}
-	if luaData != nil {
-		luaData.Close()
-	}
+	luaStates.close()
 }
 
-func processTemplate(params tParams) error {
+func processTemplate(params tParams) (err error) {
 	if *params.getVersion {
 		fmt.Printf("Version: %s\r\nProject page: https://github.com/mmalcek/bafi\r\n", version)
 		return nil
 	}
 	if *params.getHelp {
 		fmt.Println("Usage: bafi -i input.json -t template.tmpl -o output.txt")
+		fmt.Println("       bafi run [-c bafi.yaml] jobName ... | bafi run -all")
 		flag.PrintDefaults()
 		return nil
 	}
-	if *params.textTemplate == "" && *params.chatGPTkey == "" {
+	strictMode = *params.strict
+	templateVars = params.vars
+	envAllow = prepareEnvAllow(*params.envAllow)
+	if keyPaths, err = prepareKeyPaths(*params.keys); err != nil {
+		return err
+	}
+	if err := setDeterministic(params); err != nil {
+		return err
+	}
+	resetQueryData()
+	if err := loadLuaFunctions(luaFiles(params), luaOptions{packagePath: *params.luaPath, sandbox: *params.luaSandbox, timeout: *params.luaTimeout}); err != nil {
+		return err
+	}
+	if err := loadJSFunctions(jsFiles(params), jsOptions{sandbox: *params.jsSandbox, timeout: *params.jsTimeout}); err != nil {
+		return err
+	}
+	if *params.textTemplate == "" && *params.chatGPTkey == "" && *params.query == "" {
 		fmt.Println("template file must be defined: -t template.tmpl")
 		return nil
 	}
-	data, files, err := getInputData(params.inputFile)
+	options, err := prepareWriteOptions(params)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if err != nil && *params.onFail == "remove" && *params.outputFile != "" {
+			os.Remove(*params.outputFile)
+		}
+	}()
+	var data []byte
+	inputHash := sha256.New() // input data identify generated UUIDv5
+	files := params.inputList
+	if len(files) == 0 {
+		if data, files, err = getInputData(params.inputFile); err != nil {
+			return err
+		}
+		if err := checkInputList(files); err != nil {
+			return err
+		}
+	}
 	// Try identify file format by extension. Input parameter -f has priority
 	if *params.inputFormat == "" {
 		switch strings.ToLower(filepath.Ext(*params.inputFile)) {
@@ -128,16 +258,42 @@ func processTemplate(params tParams) error {
 			if err != nil {
 				return err
 			}
+			inputHash.Write(data)
 			*params.inputFormat = file["format"].(string)
 			if filesStruct[file["label"].(string)], err = mapInputData(data, params); err != nil {
 				return err
 			}
+			if schema, ok := file["schema"].(string); ok {
+				if err := validateInputData(data, filesStruct[file["label"].(string)], *params.inputFormat, schema); err != nil {
+					return fmt.Errorf("%s: %s", file["label"].(string), err.Error())
+				}
+			}
 		}
 		mapData = &filesStruct
+		if isXSD(*params.schemaFile) {
+			return fmt.Errorf("validateInput: XSD schema for multiple files must be defined per file (schema: file.xsd)")
+		}
+		if err := validateInputData(nil, mapData, "", *params.schemaFile); err != nil {
+			return err
+		}
 	} else {
+		inputHash.Write(data)
 		if mapData, err = mapInputData(data, params); err != nil {
 			return err
 		}
+		if err := validateInputData(data, mapData, *params.inputFormat, *params.schemaFile); err != nil {
+			return err
+		}
+	}
+
+	setUUIDInput(inputHash.Sum(nil))
+	if mapData, err = luaPreHook(*params.luaPre, mapData); err != nil {
+		return err
+	}
+	if *params.query != "" {
+		if mapData, err = evalQuery(*params.query, mapData); err != nil {
+			return err
+		}
 	}
 
 	if *params.chatGPTkey != "" {
@@ -151,22 +307,44 @@ func processTemplate(params tParams) error {
 		}
 		if *params.outputFile == "" {
 			fmt.Println(response.Choices[0].Message.Content)
-		} else {
-			output, err := os.Create(*params.outputFile)
-			if err != nil {
-				return fmt.Errorf("createOutputFile: %s", err.Error())
-			}
-			defer output.Close()
-			output.WriteString(response.Choices[0].Message.Content)
+			return nil
 		}
-		return nil
+		return writeOutputData([]byte(response.Choices[0].Message.Content), params.outputFile, options)
 	}
 
-	templateFile, err := readTemplate(*params.textTemplate)
-	if err != nil {
+	outputDir := *params.outputDir
+	if outputDir == "" && *params.outputFile != "" {
+		outputDir = filepath.Dir(*params.outputFile)
+	}
+	fileOutputs = newFileOutput(outputDir, *params.outputMode)
+	fileOutputs.options = options
+	var output []byte
+	if *params.textTemplate == "" { // -q without template, write query result as JSON
+		if output, err = json.MarshalIndent(mapData, "", "  "); err != nil {
+			return fmt.Errorf("query: %s", err.Error())
+		}
+	} else {
+		templateFile, err := readTemplate(*params.textTemplate)
+		if err != nil {
+			return err
+		}
+		if output, err = renderTemplate(mapData, templateFile, *params.templateDir); err != nil {
+			return err
+		}
+	}
+	if output, err = luaPostHook(*params.luaPost, output); err != nil {
+		return err
+	}
+	if err := validateOutputData(output, outputFormat(params), *params.outputSchema); err != nil {
 		return err
 	}
-	if err := writeOutputData(mapData, params.outputFile, templateFile); err != nil {
+	if err := fileOutputs.commit(); err != nil {
+		return err
+	}
+	if err := writeOutputData(output, params.outputFile, options); err != nil {
+		return err
+	}
+	if err := fileOutputs.writeManifest(*params.manifestFile); err != nil {
 		return err
 	}
 	return nil
@@ -206,6 +384,23 @@ func getInputData(input *string) (data []byte, files []map[string]interface{}, e
 	return cleanBOM(data), nil, nil
 }
 
+// checkInputList check list of input files, file, format and label are required strings, schema is optional string
+func checkInputList(files []map[string]interface{}) error {
+	for i, file := range files {
+		for _, key := range []string{"file", "format", "label"} {
+			if value, ok := file[key].(string); !ok || value == "" {
+				return fmt.Errorf("inputList: item %d: %s must be defined as string", i+1, key)
+			}
+		}
+		if schema, ok := file["schema"]; ok {
+			if _, ok := schema.(string); !ok {
+				return fmt.Errorf("inputList: item %d: schema must be string", i+1)
+			}
+		}
+	}
+	return nil
+}
+
 // mapInputData map input data to map[string]interface{}
 func mapInputData(data []byte, params tParams) (interface{}, error) {
 	switch strings.ToLower(*params.inputFormat) {
@@ -307,7 +502,7 @@ func prepareDelimiter(inputString string) rune {
 		if len(inputString) == 4 && inputString[0:2] == "0x" {
 			bytes, err := hex.DecodeString(inputString[2:4])
 			if err != nil {
-				log.Fatalf(fmt.Sprintf("error CSV delimiter: %s", err.Error()))
+				log.Fatalf("error CSV delimiter: %s", err.Error())
 			}
 			return rune(string(bytes)[0])
 		}
@@ -331,28 +526,81 @@ func readTemplate(textTemplate string) ([]byte, error) {
 	return templateFile, nil
 }
 
-// writeOutputData process template and write output
-func writeOutputData(mapData interface{}, outputFile *string, templateFile []byte) error {
-	var err error
-	template, err := template.New("new").Funcs(templateFunctions()).Parse(string(templateFile))
-	if err != nil {
-		return fmt.Errorf("parseTemplate: %s", err.Error())
-	}
-	if *outputFile == "" {
+// renderTemplate process template and return rendered output. Templates from templateDir are available as library
+func renderTemplate(mapData interface{}, templateFile []byte, templateDir string) ([]byte, error) {
+	template := template.New("new")
+	functions := renderFunctions(strictMode)
+	// include render named template to string so it can be used in pipeline {{include "header.tmpl" . | indent}}
+	// Available only in templates (not in try, lua or js) as it needs the template set being rendered
+	includeDepth := 0
+	functions["include"] = func(name string, data interface{}) (string, error) {
+		if includeDepth >= maxIncludeDepth {
+			return "", fmt.Errorf("include: max depth %d exceeded by %s (recursive include)", maxIncludeDepth, name)
+		}
+		includeDepth++
+		defer func() { includeDepth-- }()
 		output := new(bytes.Buffer)
-		if err = template.Execute(output, mapData); err != nil {
-			return fmt.Errorf("writeStdout: %s", err.Error())
+		if err := template.ExecuteTemplate(output, name, data); err != nil {
+			return "", err
 		}
-		fmt.Print(output)
-	} else {
-		output, err := os.Create(*outputFile)
+		return output.String(), nil
+	}
+	template.Funcs(functions)
+	if strictMode {
+		template.Option("missingkey=error")
+	}
+	if templateDir != "" {
+		if err := parseTemplateDir(template, templateDir); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := template.Parse(string(templateFile)); err != nil {
+		return nil, fmt.Errorf("parseTemplate: %s", err.Error())
+	}
+	output := new(bytes.Buffer)
+	if err := template.Execute(output, mapData); err != nil {
+		return nil, fmt.Errorf("executeTemplate: %s", err.Error())
+	}
+	return output.Bytes(), nil
+}
+
+// parseTemplateDir parse all *.tmpl files from directory (and subdirectories) to template set. Template name is relative path e.g. "partials/header.tmpl"
+func parseTemplateDir(tmpl *template.Template, templateDir string) error {
+	return filepath.WalkDir(templateDir, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
-			return fmt.Errorf("createOutputFile: %s", err.Error())
+			return fmt.Errorf("parseTemplateDir: %s", err.Error())
 		}
-		defer output.Close()
-		if err = template.Execute(output, mapData); err != nil {
-			return fmt.Errorf("writeOutputFile: %s", err.Error())
+		if d.IsDir() || strings.ToLower(filepath.Ext(path)) != ".tmpl" {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("parseTemplateDir: %s", err.Error())
 		}
+		name, err := filepath.Rel(templateDir, path)
+		if err != nil {
+			return fmt.Errorf("parseTemplateDir: %s", err.Error())
+		}
+		if _, err := tmpl.New(filepath.ToSlash(name)).Parse(string(content)); err != nil {
+			return fmt.Errorf("parseTemplateDir: %s", err.Error())
+		}
+		return nil
+	})
+}
+
+// writeOutputData write rendered output to stdout or atomically to file (temp file in the same directory renamed on success)
+func writeOutputData(output []byte, outputFile *string, options writeOptions) error {
+	if *outputFile == "" {
+		fmt.Print(string(output))
+		return nil
+	}
+	if options.mkdir {
+		if err := os.MkdirAll(filepath.Dir(*outputFile), 0755); err != nil {
+			return fmt.Errorf("createOutputDir: %s", err.Error())
+		}
+	}
+	if err := writeFileAtomic(*outputFile, output, options.fileMode(*outputFile), false); err != nil {
+		return fmt.Errorf("createOutputFile: %s", err.Error())
 	}
 	return nil
 }
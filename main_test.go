@@ -2,8 +2,11 @@ package main
 
 import (
 	"encoding/base64"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/clbanning/mxj/v2"
 )
@@ -21,6 +24,26 @@ func TestProcessTemplate(t *testing.T) {
 	chatGPTkey := ""
 	chatGPTmodel := ""
 	chatGPTquery := ""
+	schemaFile := ""
+	outputFormat := ""
+	outputSchema := ""
+	strict := false
+	templateDir := ""
+	outputDir := ""
+	outputMode := ""
+	manifestFile := ""
+	outputPerm := ""
+	outputMkdir := false
+	onFail := "keep"
+	envAllow := ""
+	luaPath := ""
+	luaSandbox := false
+	luaTimeout := time.Duration(0)
+	luaPre := ""
+	luaPost := ""
+	jsTimeout := time.Duration(0)
+	jsSandbox := false
+	query := ""
 
 	params := tParams{
 		inputFile:      &inputFile,
@@ -33,6 +56,34 @@ func TestProcessTemplate(t *testing.T) {
 		chatGPTkey:     &chatGPTkey,
 		chatGPTmodel:   &chatGPTmodel,
 		chatGPTquery:   &chatGPTquery,
+		schemaFile:     &schemaFile,
+		outputFormat:   &outputFormat,
+		outputSchema:   &outputSchema,
+		strict:         &strict,
+		templateDir:    &templateDir,
+		outputDir:      &outputDir,
+		outputMode:     &outputMode,
+		manifestFile:   &manifestFile,
+		outputPerm:     &outputPerm,
+		outputMkdir:    &outputMkdir,
+		onFail:         &onFail,
+		vars:           tVars{"company": "ACME"},
+		envAllow:       &envAllow,
+		keys:           &tList{},
+		luaFiles:       &tList{},
+		luaPath:        &luaPath,
+		luaSandbox:     &luaSandbox,
+		luaTimeout:     &luaTimeout,
+		luaPre:         &luaPre,
+		luaPost:        &luaPost,
+		jsFiles:        &tList{},
+		jsTimeout:      &jsTimeout,
+		jsSandbox:      &jsSandbox,
+		query:          &query,
+		seed:           new(int64),
+		now:            new(string),
+		uuidNamespace:  new(string),
+		deterministic:  new(bool),
 	}
 	err := processTemplate(params)
 	if !strings.Contains(err.Error(), "stdin: Error-noPipe") {
@@ -69,6 +120,71 @@ func TestProcessTemplate(t *testing.T) {
 		t.Errorf("result: %v", err.Error())
 	}
 
+	textTemplate = `?{"description": "{{index .filesTest.TOP_LEVEL "-description"}}"`
+	outputFormat = "json"
+	err = processTemplate(params)
+	if err == nil || !strings.Contains(err.Error(), "validateOutput: invalid JSON") {
+		t.Errorf("result: %v", err)
+	}
+	outputFormat = ""
+
+	textTemplate = `?{{var "company"}}`
+	err = processTemplate(params)
+	if err != nil {
+		t.Errorf("result: %v", err.Error())
+	}
+
+	textTemplate = `?{{.filesTest.TOP_LEVEL.DATA_LIEN}}`
+	strict = true
+	err = processTemplate(params)
+	if err == nil || !strings.Contains(err.Error(), `map has no entry for key "DATA_LIEN"`) {
+		t.Errorf("result: %v", err)
+	}
+	strictMode, strict = false, false
+
+	textTemplate = `?{{range .filesTest.TOP_LEVEL.DATA_LINE}}{{writeFile (print (index .Employee "-ID") ".txt") .val1}}{{end}}`
+	outputDir = t.TempDir()
+	manifestFile = filepath.Join(outputDir, "manifest.json")
+	err = processTemplate(params)
+	if err != nil {
+		t.Errorf("result: %v", err.Error())
+	}
+	if data, _ := os.ReadFile(filepath.Join(outputDir, "0023.txt")); string(data) != "43" {
+		t.Errorf("result: %v", string(data))
+	}
+	if data, _ := os.ReadFile(manifestFile); !strings.Contains(string(data), "0027.txt") {
+		t.Errorf("result: %v", string(data))
+	}
+	// Failed processing doesn't write any file
+	textTemplate = `?{{writeFile "partial.txt" "Hello"}}{{dict "Hello"}}`
+	err = processTemplate(params)
+	if _, statErr := os.Stat(filepath.Join(outputDir, "partial.txt")); err == nil || !os.IsNotExist(statErr) {
+		t.Errorf("result: %v, %v", statErr, err)
+	}
+	outputDir, manifestFile = "", ""
+
+	// Failed processing keeps or removes previous output file
+	outputFile = filepath.Join(t.TempDir(), "output.txt")
+	if err := os.WriteFile(outputFile, []byte("previous"), 0644); err != nil {
+		t.Fatalf("writeFile: %v", err)
+	}
+	textTemplate = `?{{dict "Hello"}}`
+	err = processTemplate(params)
+	if data, _ := os.ReadFile(outputFile); err == nil || string(data) != "previous" {
+		t.Errorf("result: %v, %v", string(data), err)
+	}
+	onFail = "remove"
+	err = processTemplate(params)
+	if _, statErr := os.Stat(outputFile); err == nil || !os.IsNotExist(statErr) {
+		t.Errorf("result: %v, %v", statErr, err)
+	}
+	onFail, outputPerm = "keep", "999"
+	err = processTemplate(params)
+	if err == nil || !strings.Contains(err.Error(), "perm: invalid file permissions 999") {
+		t.Errorf("result: %v", err)
+	}
+	outputFile, outputPerm = "", ""
+
 	inputFile = "?filesTest.yamlx"
 	err = processTemplate(params)
 	if !strings.Contains(err.Error(), "readFileList: open filesTest.yamlx") {
@@ -247,33 +363,95 @@ func TestReadTemplate(t *testing.T) {
 	}
 }
 
-func TestWriteOutputData(t *testing.T) {
+func TestRenderTemplate(t *testing.T) {
 	testData := make(map[string]interface{})
 	testData["Hello"] = "World"
-	outputFile := ""
 	templateFile := []byte(`{{define content}}`)
-	err := writeOutputData(testData, &outputFile, templateFile)
+	_, err := renderTemplate(testData, templateFile, "")
 	if !strings.Contains(err.Error(), `new:1: unexpected "content"`) {
 		t.Errorf("result: %v", err.Error())
 	}
-	templateFile = []byte(`Output test: Hello {{.Hello}} {{print "\r\n"}}`)
-	if err := writeOutputData(testData, &outputFile, templateFile); err != nil {
-		t.Errorf("result: %v", err.Error())
+	templateFile = []byte(`Output test: Hello {{.Hello}}`)
+	output, err := renderTemplate(testData, templateFile, "")
+	if err != nil || string(output) != "Output test: Hello World" {
+		t.Errorf("result: %v, %v", string(output), err)
 	}
-	outputFile = "output.txt"
-	if err := writeOutputData(testData, &outputFile, templateFile); err != nil {
-		t.Errorf("result: %v", err.Error())
+	templateDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(templateDir, "partials"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(templateDir, "partials", "header.tmpl"), []byte(`Header {{.Hello}}`), 0644); err != nil {
+		t.Fatalf("writeFile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(templateDir, "helpers.tmpl"), []byte(`{{define "greeting"}}Hi {{.}}{{end}}`), 0644); err != nil {
+		t.Fatalf("writeFile: %v", err)
+	}
+	templateFile = []byte(`{{template "partials/header.tmpl" .}}|{{include "greeting" .Hello | upper}}`)
+	output, err = renderTemplate(testData, templateFile, templateDir)
+	if err != nil || string(output) != "Header World|HI WORLD" {
+		t.Errorf("result: %v, %v", string(output), err)
 	}
+	templateFile = []byte(`{{include "missing" .}}`)
+	_, err = renderTemplate(testData, templateFile, templateDir)
+	if err == nil || !strings.Contains(err.Error(), `no template "missing"`) {
+		t.Errorf("result: %v", err)
+	}
+	templateFile = []byte(`{{define "loop"}}{{include "loop" .}}{{end}}{{include "loop" .}}`)
+	_, err = renderTemplate(testData, templateFile, "")
+	if err == nil || !strings.Contains(err.Error(), "include: max depth 100 exceeded by loop") {
+		t.Errorf("result: %v", err)
+	}
+	_, err = renderTemplate(testData, templateFile, "missingDir")
+	if err == nil || !strings.Contains(err.Error(), "parseTemplateDir:") {
+		t.Errorf("result: %v", err)
+	}
+	templateFile = []byte(`Output test: Hello {{.Hello}}`)
 	testData["Hello"] = make(chan int, 1)
-	err = writeOutputData(testData, &outputFile, templateFile)
+	_, err = renderTemplate(testData, templateFile, "")
 	if !strings.Contains(err.Error(), "can't print {{.Hello}} of type chan int") {
 		t.Errorf("result: %v", err.Error())
 	}
+}
+
+func TestWriteOutputData(t *testing.T) {
+	output := []byte("Output test: Hello World\r\n")
+	outputFile := ""
+	options := writeOptions{perm: 0644}
+	if err := writeOutputData(output, &outputFile, options); err != nil {
+		t.Errorf("result: %v", err.Error())
+	}
+	outputFile = "output.txt"
+	if err := writeOutputData(output, &outputFile, options); err != nil {
+		t.Errorf("result: %v", err.Error())
+	}
 	outputFile = "out*he\\ll//o/./txt"
-	err = writeOutputData(testData, &outputFile, templateFile)
+	err := writeOutputData(output, &outputFile, options)
 	if !strings.Contains(err.Error(), "createOutputFile:") {
 		t.Errorf("result: %v", err.Error())
 	}
+	outputFile = filepath.Join(t.TempDir(), "new", "dir", "output.txt")
+	options = writeOptions{perm: 0600, mkdir: true}
+	if err := writeOutputData(output, &outputFile, options); err != nil {
+		t.Errorf("result: %v", err.Error())
+	}
+	if info, err := os.Stat(outputFile); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("result: %v, %v", info, err)
+	}
+	// Existing file keeps its permissions if -perm is not defined
+	options = writeOptions{perm: 0644}
+	if err := writeOutputData(output, &outputFile, options); err != nil {
+		t.Errorf("result: %v", err.Error())
+	}
+	if info, err := os.Stat(outputFile); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("result: %v, %v", info, err)
+	}
+	options = writeOptions{perm: 0640, permSet: true}
+	if err := writeOutputData(output, &outputFile, options); err != nil {
+		t.Errorf("result: %v", err.Error())
+	}
+	if info, err := os.Stat(outputFile); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("result: %v, %v", info, err)
+	}
 }
 
 func TestCleanBOM(t *testing.T) {
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// dict create map from key value pairs {{$customer := dict "name" .Name "id" .ID}}
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict: expected key value pairs, got %d values", len(pairs))
+	}
+	result := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		result[toString(pairs[i])] = pairs[i+1]
+	}
+	return result, nil
+}
+
+// list create list from values {{$ids := list "a" "b" "c"}}
+func list(items ...interface{}) []interface{} {
+	result := make([]interface{}, len(items))
+	copy(result, items)
+	return result
+}
+
+// asMap convert map value to map[string]interface{}. Plain maps (and mxj.Map) are returned as is (shared with input), use copyMap before modification
+func asMap(v interface{}) (map[string]interface{}, bool) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return value, true
+	case nil:
+		return nil, false
+	}
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		return asMap(value.Elem().Interface())
+	}
+	if value.Kind() != reflect.Map {
+		return nil, false
+	}
+	if value.Type().ConvertibleTo(reflect.TypeOf(map[string]interface{}{})) { // e.g. mxj.Map
+		return value.Convert(reflect.TypeOf(map[string]interface{}{})).Interface().(map[string]interface{}), true
+	}
+	result := make(map[string]interface{}, value.Len())
+	for _, key := range value.MapKeys() {
+		result[toString(key.Interface())] = value.MapIndex(key).Interface()
+	}
+	return result, true
+}
+
+// copyMap shallow copy of map value, used before modification so input data are not changed
+func copyMap(v interface{}) (map[string]interface{}, bool) {
+	source, ok := asMap(v)
+	if !ok {
+		return nil, false
+	}
+	result := make(map[string]interface{}, len(source))
+	for key, value := range source {
+		result[key] = value
+	}
+	return result, true
+}
+
+// set value by dotted key path, missing maps are created {{$customer = set $customer "address.city" "Prague"}}.
+// New map is returned (maps on key path are copied), input is not modified
+func set(m interface{}, path string, value interface{}) (map[string]interface{}, error) {
+	root, ok := copyMap(m)
+	if !ok {
+		return nil, fmt.Errorf("set: expected map, got %T", m)
+	}
+	keys := strings.Split(path, ".")
+	current := root
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := copyMap(current[key])
+		if !ok {
+			if current[key] != nil {
+				return nil, fmt.Errorf("set: key %s is not a map", key)
+			}
+			next = make(map[string]interface{})
+		}
+		current[key] = next
+		current = next
+	}
+	current[keys[len(keys)-1]] = value
+	return root, nil
+}
+
+// unset remove value by dotted key path {{$customer = unset $customer "address.city"}}.
+// New map is returned (maps on key path are copied), input is not modified
+func unset(m interface{}, path string) (map[string]interface{}, error) {
+	root, ok := copyMap(m)
+	if !ok {
+		return nil, fmt.Errorf("unset: expected map, got %T", m)
+	}
+	keys := strings.Split(path, ".")
+	current := root
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := copyMap(current[key])
+		if !ok {
+			return root, nil
+		}
+		current[key] = next
+		current = next
+	}
+	delete(current, keys[len(keys)-1])
+	return root, nil
+}
+
+// merge deep merge maps to new map, values of later maps override earlier {{merge $defaults .Config}}
+func merge(maps ...interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for i, m := range maps {
+		if m == nil {
+			continue
+		}
+		source, ok := asMap(m)
+		if !ok {
+			return nil, fmt.Errorf("merge: arg %d: expected map, got %T", i, m)
+		}
+		mergeInto(result, source)
+	}
+	return result, nil
+}
+
+// mergeInto deep merge source to destination, nested maps are copied so source maps are not modified
+func mergeInto(destination, source map[string]interface{}) {
+	for key, value := range source {
+		sourceMap, sourceIsMap := asMap(value)
+		if !sourceIsMap {
+			destination[key] = value
+			continue
+		}
+		destinationMap, destinationIsMap := destination[key].(map[string]interface{})
+		if !destinationIsMap {
+			destinationMap = make(map[string]interface{})
+			destination[key] = destinationMap
+		}
+		mergeInto(destinationMap, sourceMap)
+	}
+}
+
+// pick new map with selected keys {{pick .Customer "name" "id" | toJSON}}
+func pick(m interface{}, keys ...string) (map[string]interface{}, error) {
+	source, ok := asMap(m)
+	if !ok {
+		return nil, fmt.Errorf("pick: expected map, got %T", m)
+	}
+	result := make(map[string]interface{})
+	for _, key := range keys {
+		if value, ok := source[key]; ok {
+			result[key] = value
+		}
+	}
+	return result, nil
+}
+
+// omit new map without selected keys {{omit .Customer "password" | toJSON}}
+func omit(m interface{}, keys ...string) (map[string]interface{}, error) {
+	source, ok := asMap(m)
+	if !ok {
+		return nil, fmt.Errorf("omit: expected map, got %T", m)
+	}
+	omitted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		omitted[key] = true
+	}
+	result := make(map[string]interface{})
+	for key, value := range source {
+		if !omitted[key] {
+			result[key] = value
+		}
+	}
+	return result, nil
+}
+
+// hasKey check if dotted key path exists {{if hasKey . "TOP_LEVEL.description"}}
+func hasKey(m interface{}, path string) bool {
+	_, ok := pathValue(m, path)
+	return ok
+}
+
+// get value by dotted key path with optional default {{get . "TOP_LEVEL.DATA_LINE.0.val1" "n/a"}}
+// Missing key without default returns nil (error in strict mode)
+func get(m interface{}, path string, defaultValue ...interface{}) (interface{}, error) {
+	if value, ok := pathValue(m, path); ok {
+		return value, nil
+	}
+	if len(defaultValue) > 0 {
+		return defaultValue[0], nil
+	}
+	return strictError[interface{}](nil, fmt.Errorf("get: key %s not found", path))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clbanning/mxj/v2"
+)
+
+func TestDictList(t *testing.T) {
+	if result, err := dict("name", "ACME", "id", 1); err != nil || !reflect.DeepEqual(result, map[string]interface{}{"name": "ACME", "id": 1}) {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := dict("name"); err == nil {
+		t.Errorf("result: expected error")
+	}
+	if result := list("a", 1); !reflect.DeepEqual(result, []interface{}{"a", 1}) {
+		t.Errorf("result: %v", result)
+	}
+	if err := runt(`{{dict "name" "ACME" "tags" (list "a" "b") | toJSON}}`, "{\n  \"name\": \"ACME\",\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ]\n}"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetUnset(t *testing.T) {
+	address := map[string]interface{}{"city": "Brno"}
+	data := map[string]interface{}{"name": "ACME", "address": address}
+	result, err := set(data, "address.city", "Prague")
+	if err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if value, _ := pathValue(result, "address.city"); value != "Prague" {
+		t.Errorf("result: %v", result)
+	}
+	// Input data (including nested maps) are not modified
+	if address["city"] != "Brno" || data["address"].(map[string]interface{})["city"] != "Brno" {
+		t.Errorf("result: input modified %v", data)
+	}
+	if result, err := set(data, "contact.email", "info@acme.com"); err != nil || result["contact"].(map[string]interface{})["email"] != "info@acme.com" || data["contact"] != nil {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := set(data, "name.first", "x"); err == nil || !strings.Contains(err.Error(), "set: key name is not a map") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := set("text", "a", 1); err == nil {
+		t.Errorf("result: expected error")
+	}
+	mxjMap := mxj.Map{"a": 1}
+	if result, err := set(mxjMap, "b", 2); err != nil || result["b"] != 2 || mxjMap["b"] != nil {
+		t.Errorf("result: %v %v %v", result, mxjMap, err)
+	}
+	if result, err := unset(data, "address.city"); err != nil || !reflect.DeepEqual(result["address"], map[string]interface{}{}) || address["city"] != "Brno" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := unset(data, "missing.key"); err != nil || !reflect.DeepEqual(result, data) {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if err := runt(`{{$d := dict "a" 1}}{{$d = set $d "b.c" 2}}{{$d = unset $d "a"}}{{toJSON $d}}`, "{\n  \"b\": {\n    \"c\": 2\n  }\n}"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	defaults := map[string]interface{}{"currency": "EUR", "address": map[string]interface{}{"city": "Prague", "zip": "11000"}}
+	config := mxj.Map{"address": map[string]interface{}{"city": "Brno"}, "name": "ACME"}
+	result, err := merge(defaults, nil, config)
+	expected := map[string]interface{}{"currency": "EUR", "name": "ACME", "address": map[string]interface{}{"city": "Brno", "zip": "11000"}}
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if defaults["address"].(map[string]interface{})["city"] != "Prague" {
+		t.Errorf("source map must not be modified")
+	}
+	if _, err := merge(defaults, "text"); err == nil || !strings.Contains(err.Error(), "merge: arg 1") {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestPickOmit(t *testing.T) {
+	data := map[string]interface{}{"name": "ACME", "id": 1, "password": "secret"}
+	if result, err := pick(data, "name", "id", "missing"); err != nil || !reflect.DeepEqual(result, map[string]interface{}{"name": "ACME", "id": 1}) {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := omit(data, "password"); err != nil || !reflect.DeepEqual(result, map[string]interface{}{"name": "ACME", "id": 1}) {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := pick(nil, "a"); err == nil {
+		t.Errorf("result: expected error")
+	}
+	if _, err := omit(1, "a"); err == nil {
+		t.Errorf("result: expected error")
+	}
+}
+
+func TestHasKeyGet(t *testing.T) {
+	data := map[string]interface{}{"TOP_LEVEL": map[string]interface{}{"DATA_LINE": []interface{}{map[string]interface{}{"val1": "5"}}}}
+	if !hasKey(data, "TOP_LEVEL.DATA_LINE.0.val1") || hasKey(data, "TOP_LEVEL.missing") {
+		t.Errorf("result: hasKey")
+	}
+	if result, err := get(data, "TOP_LEVEL.DATA_LINE.0.val1"); err != nil || result != "5" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := get(data, "TOP_LEVEL.missing", "n/a"); err != nil || result != "n/a" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := renderCall("get", data, "TOP_LEVEL.missing"); err != nil || result != nil {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := get(data, "TOP_LEVEL.missing"); err == nil || !strings.Contains(err.Error(), "get: key TOP_LEVEL.missing not found") {
+		t.Errorf("result: %v", err)
+	}
+}
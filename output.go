@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// writeOptions options for writing output files
+type writeOptions struct {
+	perm    os.FileMode // output file permissions
+	permSet bool        // -perm defined, applied also to existing files (otherwise existing file keeps its permissions)
+	mkdir   bool        // create missing parent directories
+}
+
+// prepareWriteOptions validate output parameters. Empty -perm is 0644 for new files and existing files keep their permissions
+func prepareWriteOptions(params tParams) (writeOptions, error) {
+	options := writeOptions{perm: 0644, mkdir: *params.outputMkdir}
+	if *params.outputPerm != "" {
+		perm, err := strconv.ParseUint(*params.outputPerm, 8, 32)
+		if err != nil || perm > 0777 {
+			return writeOptions{}, fmt.Errorf("perm: invalid file permissions %s (expected octal value e.g. 0644)", *params.outputPerm)
+		}
+		options.perm, options.permSet = os.FileMode(perm), true
+	}
+	if *params.onFail != "keep" && *params.onFail != "remove" {
+		return writeOptions{}, fmt.Errorf("onfail: unknown value %s (accepted values are keep, remove)", *params.onFail)
+	}
+	return options, nil
+}
+
+// fileMode permissions of written file, existing file keeps its permissions unless -perm is defined
+func (o writeOptions) fileMode(path string) os.FileMode {
+	if !o.permSet {
+		if info, err := os.Stat(path); err == nil {
+			return info.Mode().Perm()
+		}
+	}
+	return o.perm
+}
+
+// writeFileAtomic write data to temp file in the same directory and rename it to target on success,
+// so target file is never left half-written. If appendData is set, data are appended to the end of file (O_APPEND)
+func writeFileAtomic(path string, data []byte, perm os.FileMode, appendData bool) error {
+	if appendData {
+		return appendFile(path, data, perm)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after successful rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// appendFile append data to the end of file, file is created if it doesn't exist
+func appendFile(path string, data []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(path, perm)
+}
+
+// fileOutput state of files written by writeFile template function during one run.
+// Files are staged in memory and written by commit only if whole processing succeeds
+type fileOutput struct {
+	dir     string         // output directory, files are written relative to it
+	mode    string         // default write policy: overwrite, append, skip, fail
+	options writeOptions   // file permissions (-perm)
+	files   []writtenFile  // manifest, one record per file in order of first write
+	index   map[string]int // position of file in files (next writes to the same file are appended)
+}
+
+// writtenFile manifest record
+type writtenFile struct {
+	File    string `json:"file"`
+	Bytes   int    `json:"bytes"`
+	Mode    string `json:"mode"`
+	content []byte // staged content written by commit
+}
+
+var fileOutputs = newFileOutput("", "")
+
+// newFileOutput prepare file output state, default mode is overwrite
+func newFileOutput(dir, mode string) *fileOutput {
+	if mode == "" {
+		mode = "overwrite"
+	}
+	return &fileOutput{dir: dir, mode: mode, options: writeOptions{perm: 0644}, files: make([]writtenFile, 0), index: make(map[string]int)}
+}
+
+// writeFile write content to file relative to output directory {{writeFile (print "customers/" .id ".xml") (include "customer.tmpl" .)}}
+// optional mode overrides -omode: overwrite, append, skip (keep existing file), fail (error if file exists).
+// Repeated writes to the same file in one run are appended. Files are written after successful processing only
+func writeFile(path string, content interface{}, mode ...string) (string, error) {
+	writeMode := fileOutputs.mode
+	if len(mode) > 0 {
+		writeMode = mode[0]
+	}
+	fullPath, err := fileOutputs.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	data := []byte(toString(content))
+	if i, ok := fileOutputs.index[fullPath]; ok {
+		fileOutputs.files[i].content = append(fileOutputs.files[i].content, data...)
+		fileOutputs.files[i].Bytes += len(data)
+		return "", nil
+	}
+	_, statErr := os.Stat(fullPath)
+	exists := statErr == nil
+	switch writeMode {
+	case "overwrite", "append":
+	case "skip":
+		if exists {
+			return "", nil
+		}
+	case "fail":
+		if exists {
+			return "", fmt.Errorf("writeFile: file %s already exists", path)
+		}
+	default:
+		return "", fmt.Errorf("writeFile: unknown mode %s (accepted values are overwrite, append, skip, fail)", writeMode)
+	}
+	fileOutputs.index[fullPath] = len(fileOutputs.files)
+	fileOutputs.files = append(fileOutputs.files, writtenFile{File: fullPath, Bytes: len(data), Mode: writeMode, content: data})
+	return "", nil
+}
+
+// commit write staged files to disk (each file atomically)
+func (o *fileOutput) commit() error {
+	for _, file := range o.files {
+		if err := os.MkdirAll(filepath.Dir(file.File), 0755); err != nil {
+			return fmt.Errorf("writeFile: %s", err.Error())
+		}
+		if err := writeFileAtomic(file.File, file.content, o.options.fileMode(file.File), file.Mode == "append"); err != nil {
+			return fmt.Errorf("writeFile: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// resolve path relative to output directory, path can't leave output directory
+func (o *fileOutput) resolve(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("writeFile: empty file name")
+	}
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("writeFile: path %s must be relative to output directory", path)
+	}
+	clean := filepath.Clean(path)
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("writeFile: path %s is outside of output directory", path)
+	}
+	return filepath.Join(o.dir, clean), nil
+}
+
+// writeManifest write list of files written by writeFile as JSON
+func (o *fileOutput) writeManifest(manifestFile string) error {
+	if manifestFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(o.files, "", "  ")
+	if err != nil {
+		return fmt.Errorf("writeManifest: %s", err.Error())
+	}
+	if err := os.WriteFile(manifestFile, data, 0644); err != nil {
+		return fmt.Errorf("writeManifest: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	fileOutputs = newFileOutput(dir, "")
+	defer func() { fileOutputs = newFileOutput("", "") }()
+	if _, err := writeFile("customers/1.txt", "Hello"); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := writeFile("customers/1.txt", " World"); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	// Files are staged until commit, repeated writes are merged to one manifest record
+	if _, err := os.Stat(filepath.Join(dir, "customers", "1.txt")); !os.IsNotExist(err) {
+		t.Errorf("result: file written before commit %v", err)
+	}
+	if len(fileOutputs.files) != 1 || fileOutputs.files[0].Bytes != 11 || fileOutputs.files[0].Mode != "overwrite" {
+		t.Errorf("result: %v", fileOutputs.files)
+	}
+	if err := fileOutputs.commit(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "customers", "1.txt"))
+	if string(data) != "Hello World" {
+		t.Errorf("result: %v", string(data))
+	}
+	// New run - existing files
+	fileOutputs = newFileOutput(dir, "skip")
+	if _, err := writeFile("customers/1.txt", "Skipped"); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := writeFile("customers/1.txt", "!", "append"); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if err := fileOutputs.commit(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "customers", "1.txt"))
+	if string(data) != "Hello World!" {
+		t.Errorf("result: %v", string(data))
+	}
+	fileOutputs = newFileOutput(dir, "")
+	if _, err := writeFile("customers/1.txt", "Hello", "fail"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := writeFile("customers/1.txt", "Hello", "overwrite"); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := writeFile("2.txt", "Hello", "replace"); err == nil || !strings.Contains(err.Error(), "unknown mode replace") {
+		t.Errorf("result: %v", err)
+	}
+	if len(fileOutputs.files) != 1 || fileOutputs.files[0].Mode != "overwrite" {
+		t.Errorf("result: %v", fileOutputs.files)
+	}
+	if err := fileOutputs.commit(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "customers", "1.txt"))
+	if string(data) != "Hello" {
+		t.Errorf("result: %v", string(data))
+	}
+}
+
+func TestFileOutputResolve(t *testing.T) {
+	output := newFileOutput("out", "")
+	if result, err := output.resolve("a/../b.txt"); err != nil || result != filepath.Join("out", "b.txt") {
+		t.Errorf("result: %v, %v", result, err)
+	}
+	if _, err := output.resolve("../b.txt"); err == nil || !strings.Contains(err.Error(), "outside of output directory") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := output.resolve("/tmp/b.txt"); err == nil || !strings.Contains(err.Error(), "must be relative") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := output.resolve(""); err == nil {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestWriteManifest(t *testing.T) {
+	output := newFileOutput("", "")
+	if err := output.writeManifest(""); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	output.files = append(output.files, writtenFile{File: "a.txt", Bytes: 5, Mode: "overwrite"})
+	manifest := filepath.Join(t.TempDir(), "manifest.json")
+	if err := output.writeManifest(manifest); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	data, _ := os.ReadFile(manifest)
+	var files []writtenFile
+	if err := json.Unmarshal(data, &files); err != nil || len(files) != 1 || files[0].File != "a.txt" {
+		t.Errorf("result: %v, %v", string(data), err)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "output.txt")
+	if err := writeFileAtomic(path, []byte("Hello"), 0644, false); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if err := writeFileAtomic(path, []byte(" World"), 0644, true); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "Hello World" {
+		t.Errorf("result: %v", string(data))
+	}
+	appended := filepath.Join(dir, "appended.txt")
+	if err := writeFileAtomic(appended, []byte("Hello"), 0600, true); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if info, err := os.Stat(appended); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("result: %v, %v", info, err)
+	}
+	os.Remove(appended)
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "output.txt"), []byte("Hello"), 0644, false); err == nil {
+		t.Errorf("result: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("result: temp files left %v", entries)
+	}
+}
+
+func TestPrepareWriteOptions(t *testing.T) {
+	perm, mkdir, onFail := "0600", true, "remove"
+	options, err := prepareWriteOptions(tParams{outputPerm: &perm, outputMkdir: &mkdir, onFail: &onFail})
+	if err != nil || options.perm != 0600 || !options.permSet || !options.mkdir {
+		t.Errorf("result: %v, %v", options, err)
+	}
+	perm = ""
+	if options, err := prepareWriteOptions(tParams{outputPerm: &perm, outputMkdir: &mkdir, onFail: &onFail}); err != nil || options.perm != 0644 || options.permSet {
+		t.Errorf("result: %v, %v", options, err)
+	}
+	onFail = "backup"
+	if _, err := prepareWriteOptions(tParams{outputPerm: &perm, outputMkdir: &mkdir, onFail: &onFail}); err == nil {
+		t.Errorf("result: %v", err)
+	}
+}
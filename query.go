@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/PaesslerAG/gval"
+	"github.com/PaesslerAG/jsonpath"
+)
+
+// maxQueryCache max number of compiled expressions and converted data kept by query (oldest are removed)
+const maxQueryCache = 256
+
+var (
+	queryLanguage = gval.Full(jsonpath.Language())                // JSONPath with filter expressions e.g. $..[?(@.val1 > 10)]
+	queryCache    = newQueryCache[string, gval.Evaluable]()       // compiled expressions
+	queryData     = newQueryCache[queryDataKey, queryDataEntry]() // data converted by plainValue during one run
+)
+
+// tQueryCache cache limited to maxQueryCache entries, oldest entry is removed when cache is full
+type tQueryCache[K comparable, V any] struct {
+	mutex   sync.Mutex
+	entries map[K]V
+	order   []K // keys in insertion order
+}
+
+// newQueryCache create empty cache
+func newQueryCache[K comparable, V any]() *tQueryCache[K, V] {
+	return &tQueryCache[K, V]{entries: make(map[K]V)}
+}
+
+// get cached value
+func (c *tQueryCache[K, V]) get(key K) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	value, ok := c.entries[key]
+	return value, ok
+}
+
+// put store value, oldest entry is removed if cache is full
+func (c *tQueryCache[K, V]) put(key K, value V) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		if len(c.order) >= maxQueryCache {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = value
+}
+
+// reset remove all entries
+func (c *tQueryCache[K, V]) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = make(map[K]V)
+	c.order = nil
+}
+
+// queryDataKey identity of map, slice or pointer passed to query
+type queryDataKey struct {
+	dataType reflect.Type
+	pointer  uintptr
+	length   int
+}
+
+// queryDataEntry converted data, source keeps original data referenced so its address can't be reused while entry is cached
+type queryDataEntry struct {
+	source interface{}
+	plain  interface{}
+}
+
+// resetQueryData clear converted data at start of run
+func resetQueryData() {
+	queryData.reset()
+}
+
+// queryPlainData convert data to plain maps and arrays, maps, slices and pointers are converted once (query is often called in range).
+// Only last maxQueryCache converted data are kept
+func queryPlainData(data interface{}) (interface{}, error) {
+	value := reflect.ValueOf(data)
+	switch value.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Ptr:
+		if value.IsNil() {
+			return nil, nil
+		}
+	default:
+		return plainValue(data)
+	}
+	key := queryDataKey{dataType: value.Type(), pointer: value.Pointer()}
+	if value.Kind() != reflect.Ptr {
+		key.length = value.Len()
+	}
+	if cached, ok := queryData.get(key); ok {
+		return cached.plain, nil
+	}
+	plain, err := plainValue(data)
+	if err != nil {
+		return nil, err
+	}
+	queryData.put(key, queryDataEntry{source: data, plain: plain})
+	return plain, nil
+}
+
+// isQueryNotFound check if evaluation error is missing key or index
+func isQueryNotFound(err error) bool {
+	message := strings.TrimPrefix(err.Error(), "query: ")
+	return strings.HasPrefix(message, "unknown key ") || strings.HasSuffix(message, " out of bounds")
+}
+
+// compileQuery compile JSONPath expression, last maxQueryCache compiled expressions are cached (query is often called in range)
+func compileQuery(expression string) (gval.Evaluable, error) {
+	if cached, ok := queryCache.get(expression); ok {
+		return cached, nil
+	}
+	evaluable, err := queryLanguage.NewEvaluable(expression)
+	if err != nil {
+		return nil, err
+	}
+	queryCache.put(expression, evaluable)
+	return evaluable, nil
+}
+
+// evalQuery evaluate JSONPath expression against data, data are converted to plain maps and arrays so all input formats behave the same
+func evalQuery(expression string, data interface{}) (interface{}, error) {
+	evaluable, err := compileQuery(expression)
+	if err != nil {
+		return nil, fmt.Errorf("query: %s", err.Error())
+	}
+	plainData, err := queryPlainData(data)
+	if err != nil {
+		return nil, fmt.Errorf("query: %s", err.Error())
+	}
+	result, err := evaluable(context.Background(), plainData)
+	if err != nil {
+		return nil, fmt.Errorf("query: %s", err.Error())
+	}
+	return result, nil
+}
+
+// query evaluate JSONPath expression {{range query "$.TOP_LEVEL.DATA_LINE[?(@.val1 > 10)]" .}}...{{end}}
+// Missing key or index returns nil (error in strict mode), invalid expression and other evaluation errors are always error
+func query(expression string, data interface{}) (interface{}, error) {
+	result, err := evalQuery(expression, data)
+	if err != nil && isQueryNotFound(err) {
+		return strictError[interface{}](nil, err)
+	}
+	return result, err
+}
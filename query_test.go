@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clbanning/mxj/v2"
+)
+
+func TestQuery(t *testing.T) {
+	data, err := mxj.NewMapXml([]byte(`<TOP_LEVEL><DATA_LINE><val1>5</val1></DATA_LINE><DATA_LINE><val1>43</val1></DATA_LINE><DATA_LINE><val1>14</val1></DATA_LINE></TOP_LEVEL>`))
+	if err != nil {
+		t.Fatalf("mapXML: %v", err)
+	}
+	if result, err := query("$.TOP_LEVEL.DATA_LINE[?(@.val1 > 10)].val1", data); err != nil || !reflect.DeepEqual(result, []interface{}{"43", "14"}) {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := query("$..val1", data); err != nil || !reflect.DeepEqual(result, []interface{}{"5", "43", "14"}) {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := query("$.TOP_LEVEL.DATA_LINE[0].val1", data); err != nil || result != "5" {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := renderCall("query", "$.MISSING", data); err != nil || result != nil {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if result, err := renderCall("query", "$.TOP_LEVEL.DATA_LINE[5]", data); err != nil || result != nil {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := query("$.TOP_LEVEL[?(@.val1 >", data); err == nil || !strings.Contains(err.Error(), "query:") {
+		t.Errorf("result: %v", err)
+	}
+	// Evaluation errors other than missing key or index are returned in non-strict mode
+	if _, err := renderCall("query", "$.TOP_LEVEL.DATA_LINE[0].val1.x", data); err == nil || !strings.Contains(err.Error(), "query: unsupported value type string") {
+		t.Errorf("result: %v", err)
+	}
+	strictMode = true
+	defer func() { strictMode = false }()
+	if _, err := query("$.MISSING", data); err == nil || !strings.Contains(err.Error(), "query: unknown key MISSING") {
+		t.Errorf("result: %v", err)
+	}
+	items := map[string]interface{}{"items": []interface{}{map[string]interface{}{"name": "ACME", "active": true}, map[string]interface{}{"name": "Globex", "active": false}}}
+	if err := runtv(`{{range query "$.items[?(@.active)]" .}}{{.name}}{{end}}`, "ACME", items); err != nil {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestQueryPlainData(t *testing.T) {
+	resetQueryData()
+	data := map[string]interface{}{"items": []int{1, 2}}
+	first, err := queryPlainData(data)
+	if err != nil || !reflect.DeepEqual(first, map[string]interface{}{"items": []interface{}{int64(1), int64(2)}}) {
+		t.Errorf("result: %v %v", first, err)
+	}
+	// Converted once per run
+	first.(map[string]interface{})["cached"] = true
+	if second, _ := queryPlainData(data); second.(map[string]interface{})["cached"] != true {
+		t.Errorf("result: data converted again %v", second)
+	}
+	resetQueryData()
+	if third, _ := queryPlainData(data); third.(map[string]interface{})["cached"] != nil {
+		t.Errorf("result: converted data not reset %v", third)
+	}
+	if result, err := queryPlainData("text"); err != nil || result != "text" {
+		t.Errorf("result: %v %v", result, err)
+	}
+}
+
+func TestEvalQuery(t *testing.T) {
+	data := []map[string]interface{}{{"name": "ACME", "price": 10}, {"name": "Globex", "price": 20}}
+	if result, err := evalQuery("$[?(@.price >= 15)].name", data); err != nil || !reflect.DeepEqual(result, []interface{}{"Globex"}) {
+		t.Errorf("result: %v %v", result, err)
+	}
+	if _, err := evalQuery("$[0].missing", data); err == nil || !strings.Contains(err.Error(), "query:") {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := compileQuery("$[0].name"); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if _, ok := queryCache.get("$[0].name"); !ok {
+		t.Errorf("result: expression not cached")
+	}
+	// Cache is limited, oldest expressions are removed
+	for x := 0; x < maxQueryCache; x++ {
+		if _, err := compileQuery(fmt.Sprintf("$[%d].name", x+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := queryCache.get("$[0].name"); ok || len(queryCache.entries) != maxQueryCache {
+		t.Errorf("result: cache not limited %d", len(queryCache.entries))
+	}
+	resetQueryData()
+	for x := 0; x <= maxQueryCache; x++ {
+		if _, err := queryPlainData([]int{x}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(queryData.entries) != maxQueryCache || len(queryData.order) != maxQueryCache {
+		t.Errorf("result: converted data not limited %d", len(queryData.entries))
+	}
+}
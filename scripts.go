@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// maxScriptEngines max number of lua states or javascript runtimes of pool (concurrent and nested calls)
+const maxScriptEngines = 32
+
+// tList list of values defined by repeatable parameter e.g. -lua a.lua -lua ./scripts
+type tList []string
+
+// String implements flag.Value
+func (l *tList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value
+func (l *tList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// scriptFiles get list of script files/directories. List (-lua, -js) has priority, then environment variable
+// (list separated by OS path list separator) and default file if exists in dir
+func scriptFiles(list []string, env, defaultFile, dir string) []string {
+	if len(list) > 0 {
+		return list
+	}
+	if value := os.Getenv(env); value != "" {
+		return filepath.SplitList(value)
+	}
+	if _, err := os.Stat(filepath.Join(dir, defaultFile)); err == nil {
+		return []string{defaultFile}
+	}
+	return nil
+}
+
+// expandScriptFiles expand directories to files with extension (e.g. ".lua") sorted by name, files are kept in order
+func expandScriptFiles(paths []string, ext string) ([]string, error) {
+	files := make([]string, 0)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		dirFiles, err := filepath.Glob(filepath.Join(path, "*"+ext))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(dirFiles)
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
+
+// scriptPool pool of script engines (lua states, javascript runtimes) with loaded scripts. Engines are not goroutine-safe,
+// so each call checks out own engine. Engines are created on demand (scripts are loaded once per engine) up to maxScriptEngines and reused
+type scriptPool[T any] struct {
+	name      string            // engine name used in errors e.g. "lua states"
+	create    func() (T, error) // create engine and load scripts
+	destroy   func(T)           // release engine, nil if not needed
+	mutex     sync.Mutex        // guards idle, engines and created
+	available *sync.Cond        // signaled when engine is returned or can be created
+	idle      []T               // engines ready to use
+	engines   []T               // all created engines
+	created   int               // number of created engines including engines being created (max maxScriptEngines)
+}
+
+// newScriptPool create pool and first engine, so script errors are reported before rendering
+func newScriptPool[T any](name string, create func() (T, error), destroy func(T)) (*scriptPool[T], error) {
+	engine, err := create()
+	if err != nil {
+		return nil, err
+	}
+	pool := &scriptPool[T]{name: name, create: create, destroy: destroy, idle: []T{engine}, engines: []T{engine}, created: 1}
+	pool.available = sync.NewCond(&pool.mutex)
+	return pool, nil
+}
+
+// get check out idle engine or create new one. If all maxScriptEngines engines are in use, top-level call (wait)
+// waits for returned engine, nested call (script calling script, its caller holds an engine) fails instead of deadlock
+func (p *scriptPool[T]) get(wait bool) (T, error) {
+	var engine T
+	p.mutex.Lock()
+	for len(p.idle) == 0 && p.created >= maxScriptEngines {
+		if !wait {
+			p.mutex.Unlock()
+			return engine, fmt.Errorf("all %d %s are in use (too many nested calls)", maxScriptEngines, p.name)
+		}
+		p.available.Wait()
+	}
+	if n := len(p.idle); n > 0 {
+		engine = p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mutex.Unlock()
+		return engine, nil
+	}
+	p.created++ // reserved while engine is created
+	p.mutex.Unlock()
+	engine, err := p.create()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if err != nil {
+		p.created--
+		p.available.Signal()
+		return engine, err
+	}
+	p.engines = append(p.engines, engine)
+	return engine, nil
+}
+
+// put return engine to pool
+func (p *scriptPool[T]) put(engine T) {
+	p.mutex.Lock()
+	p.idle = append(p.idle, engine)
+	p.mutex.Unlock()
+	p.available.Signal()
+}
+
+// close release all engines of pool
+func (p *scriptPool[T]) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.destroy != nil {
+		for _, engine := range p.engines {
+			p.destroy(engine)
+		}
+	}
+	p.idle, p.engines, p.created = nil, nil, 0
+}
+
+// plainValue convert Go value to plain types passed to scripts (map[string]interface{}, []interface{}, int64, float64, string, bool, nil).
+// Decimal values and dates are converted to strings
+func plainValue(v interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case nil, string, bool, float64, int64:
+		return value, nil
+	case decimal.Decimal: // keep precision, scripts can convert it to number if needed
+		return value.String(), nil
+	case time.Time:
+		return value.Format(time.RFC3339Nano), nil
+	}
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(value.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), nil
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Bool:
+		return value.Bool(), nil
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil, nil
+		}
+		return plainValue(value.Elem().Interface())
+	case reflect.Map:
+		mapData := make(map[string]interface{}, value.Len())
+		for _, key := range value.MapKeys() {
+			item, err := plainValue(value.MapIndex(key).Interface())
+			if err != nil {
+				return nil, err
+			}
+			mapData[toString(key.Interface())] = item
+		}
+		return mapData, nil
+	case reflect.Slice, reflect.Array:
+		array := make([]interface{}, value.Len())
+		for x := range array {
+			item, err := plainValue(value.Index(x).Interface())
+			if err != nil {
+				return nil, err
+			}
+			array[x] = item
+		}
+		return array, nil
+	default:
+		if stringer, ok := v.(fmt.Stringer); ok { // e.g. bson ObjectID
+			return stringer.String(), nil
+		}
+		return nil, fmt.Errorf("unsupported type: %T", v)
+	}
+}
+
+// sortedKeys keys of map sorted by name, maps are passed to scripts in the same order on every run
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
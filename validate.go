@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/clbanning/mxj/v2"
+	"github.com/jacoelho/xsd"
+	xsdErrors "github.com/jacoelho/xsd/errors"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"gopkg.in/yaml.v3"
+)
+
+// validateInputData validate input against schema. XSD (*.xsd) is applied to raw XML data, JSON Schema (draft 2020-12) to mapped data
+func validateInputData(data []byte, mapData interface{}, inputFormat string, schemaFile string) error {
+	if schemaFile == "" {
+		return nil
+	}
+	if isXSD(schemaFile) {
+		if strings.ToLower(inputFormat) != "xml" {
+			return fmt.Errorf("validateInput: XSD schema can be used only with xml input")
+		}
+		return validateXSD(data, schemaFile, "validateInput")
+	}
+	return validateJSONSchema(mapData, schemaFile, "validateInput")
+}
+
+// outputFormat get declared output format. Parameter -of has priority, if not defined and output schema is set try detect format by output file extension
+func outputFormat(params tParams) string {
+	if *params.outputFormat != "" {
+		return strings.ToLower(*params.outputFormat)
+	}
+	if *params.outputSchema == "" {
+		return ""
+	}
+	if isXSD(*params.outputSchema) {
+		return "xml"
+	}
+	switch strings.ToLower(filepath.Ext(*params.outputFile)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".xml":
+		return "xml"
+	default:
+		return "json"
+	}
+}
+
+// validateOutputData parse rendered output back with declared format and optionally validate it against JSON Schema or XSD
+func validateOutputData(output []byte, outputFormat string, schemaFile string) error {
+	var data interface{}
+	switch outputFormat {
+	case "":
+		return nil
+	case "json":
+		if err := json.Unmarshal(output, &data); err != nil {
+			if syntaxErr, ok := err.(*json.SyntaxError); ok {
+				line, column := offsetPosition(output, syntaxErr.Offset)
+				return fmt.Errorf("validateOutput: invalid JSON at line %d, column %d: %s", line, column, err.Error())
+			}
+			return fmt.Errorf("validateOutput: invalid JSON: %s", err.Error())
+		}
+	case "yaml":
+		if err := yaml.Unmarshal(output, &data); err != nil {
+			return fmt.Errorf("validateOutput: invalid YAML: %s", err.Error())
+		}
+	case "xml":
+		if err := checkXML(output); err != nil {
+			return fmt.Errorf("validateOutput: invalid XML: %s", err.Error())
+		}
+		if schemaFile != "" && !isXSD(schemaFile) {
+			mapData, err := mxj.NewMapXml(output)
+			if err != nil {
+				return fmt.Errorf("validateOutput: invalid XML: %s", err.Error())
+			}
+			data = mapData
+		}
+	default:
+		return fmt.Errorf("validateOutput: unknown output format: %s (accepted values are json, yaml, xml)", outputFormat)
+	}
+	if schemaFile == "" {
+		return nil
+	}
+	if isXSD(schemaFile) {
+		if outputFormat != "xml" {
+			return fmt.Errorf("validateOutput: XSD schema can be used only with xml output")
+		}
+		return validateXSD(output, schemaFile, "validateOutput")
+	}
+	return validateJSONSchema(data, schemaFile, "validateOutput")
+}
+
+// checkXML check if XML is well-formed and has single root element
+func checkXML(data []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	depth, roots := 0, 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch token.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				roots++
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if roots != 1 {
+		return fmt.Errorf("expected single root element, found %d", roots)
+	}
+	return nil
+}
+
+// offsetPosition convert byte offset to line and column
+func offsetPosition(data []byte, offset int64) (line, column int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	line = 1 + bytes.Count(data[:offset], []byte("\n"))
+	column = int(offset) - bytes.LastIndexByte(data[:offset], '\n') - 1
+	return line, column
+}
+
+// isXSD check if schema file is XML Schema (by extension)
+func isXSD(schemaFile string) bool {
+	return strings.ToLower(filepath.Ext(schemaFile)) == ".xsd"
+}
+
+// validateJSONSchema validate data against JSON Schema and report all violations
+func validateJSONSchema(data interface{}, schemaFile string, label string) error {
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	schema, err := compiler.Compile(schemaFile)
+	if err != nil {
+		return fmt.Errorf("%s: loadSchema: %s", label, err.Error())
+	}
+	// Convert data to plain JSON types (mxj.Map, bson, mt940 structs, ...)
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("%s: jsonMarshal: %s", label, err.Error())
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("%s: jsonUnmarshal: %s", label, err.Error())
+	}
+	if err := schema.Validate(instance); err != nil {
+		validationError, ok := err.(*jsonschema.ValidationError)
+		if !ok {
+			return fmt.Errorf("%s: %s", label, err.Error())
+		}
+		violations := make([]string, 0)
+		collectViolations(validationError, &violations)
+		return violationsError(label, violations)
+	}
+	return nil
+}
+
+// collectViolations flatten nested JSON Schema errors to list of "at '/path': message"
+func collectViolations(e *jsonschema.ValidationError, violations *[]string) {
+	if len(e.Causes) == 0 {
+		*violations = append(*violations, e.Error())
+		return
+	}
+	for _, cause := range e.Causes {
+		collectViolations(cause, violations)
+	}
+}
+
+// validateXSD validate XML data against XSD and report all violations
+func validateXSD(data []byte, schemaFile string, label string) error {
+	schema, err := xsd.LoadFile(schemaFile)
+	if err != nil {
+		return fmt.Errorf("%s: loadSchema: %s", label, err.Error())
+	}
+	if err := schema.Validate(bytes.NewReader(data)); err != nil {
+		list, ok := xsdErrors.AsValidations(err)
+		if !ok {
+			return fmt.Errorf("%s: %s", label, err.Error())
+		}
+		violations := make([]string, len(list))
+		for i := range list {
+			violations[i] = list[i].Error()
+		}
+		return violationsError(label, violations)
+	}
+	return nil
+}
+
+// violationsError format list of violations to single error
+func violationsError(label string, violations []string) error {
+	return fmt.Errorf("%s: %d schema violation(s)\r\n - %s", label, len(violations), strings.Join(violations, "\r\n - "))
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testJSONSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["TOP_LEVEL"],
+  "properties": {
+    "TOP_LEVEL": {
+      "type": "object",
+      "required": ["DATA_LINE"],
+      "properties": {
+        "DATA_LINE": {
+          "type": "array",
+          "items": {"type": "object", "required": ["val1", "val2"], "properties": {"val1": {"type": "string", "pattern": "^[0-9]+$"}}}
+        }
+      }
+    }
+  }
+}`
+
+const testXSD = `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="person">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="name" type="xs:string"/>
+        <xs:element name="age" type="xs:integer"/>
+      </xs:sequence>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`
+
+func writeTestFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("writeTestFile: %v", err)
+	}
+	return path
+}
+
+func TestValidateInputData(t *testing.T) {
+	if err := validateInputData(nil, nil, "json", ""); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	schema := writeTestFile(t, "schema.json", testJSONSchema)
+	data, err := os.ReadFile("testdata.xml")
+	if err != nil {
+		t.Fatalf("readFile: %v", err)
+	}
+	inputFormat := "xml"
+	mapData, err := mapInputData(data, tParams{inputFormat: &inputFormat})
+	if err != nil {
+		t.Fatalf("mapInputData: %v", err)
+	}
+	if err := validateInputData(data, mapData, "xml", schema); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	invalid := map[string]interface{}{"TOP_LEVEL": map[string]interface{}{"DATA_LINE": []interface{}{
+		map[string]interface{}{"val1": "x"},
+	}}}
+	err = validateInputData(nil, invalid, "json", schema)
+	if err == nil || !strings.Contains(err.Error(), "2 schema violation(s)") ||
+		!strings.Contains(err.Error(), "/TOP_LEVEL/DATA_LINE/0") {
+		t.Errorf("result: %v", err)
+	}
+	xsdFile := writeTestFile(t, "schema.xsd", testXSD)
+	if err := validateInputData([]byte(`<person><name>John</name><age>30</age></person>`), nil, "xml", xsdFile); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	err = validateInputData([]byte(`<person><name>John</name><age>old</age></person>`), nil, "xml", xsdFile)
+	if err == nil || !strings.Contains(err.Error(), "1 schema violation(s)") || !strings.Contains(err.Error(), "/person/age") {
+		t.Errorf("result: %v", err)
+	}
+	err = validateInputData([]byte(`{}`), nil, "json", xsdFile)
+	if err == nil || !strings.Contains(err.Error(), "XSD schema can be used only with xml input") {
+		t.Errorf("result: %v", err)
+	}
+	err = validateInputData(nil, invalid, "json", "missing.json")
+	if err == nil || !strings.Contains(err.Error(), "validateInput: loadSchema:") {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestOutputFormat(t *testing.T) {
+	outputFile := "output.yml"
+	format := ""
+	schema := ""
+	params := tParams{outputFile: &outputFile, outputFormat: &format, outputSchema: &schema}
+	if result := outputFormat(params); result != "" {
+		t.Errorf("result: %v", result)
+	}
+	schema = "schema.json"
+	if result := outputFormat(params); result != "yaml" {
+		t.Errorf("result: %v", result)
+	}
+	schema = "schema.xsd"
+	if result := outputFormat(params); result != "xml" {
+		t.Errorf("result: %v", result)
+	}
+	format = "JSON"
+	if result := outputFormat(params); result != "json" {
+		t.Errorf("result: %v", result)
+	}
+}
+
+func TestValidateOutputData(t *testing.T) {
+	if err := validateOutputData([]byte(`{"a": 1,}`), "", ""); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if err := validateOutputData([]byte(`{"a": 1}`), "json", ""); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	err := validateOutputData([]byte("{\n\"a\": \"x\"y\"\n}"), "json", "")
+	if err == nil || !strings.Contains(err.Error(), "invalid JSON at line 2, column 9") {
+		t.Errorf("result: %v", err)
+	}
+	if err := validateOutputData([]byte("a: 1\nb: [1, 2]"), "yaml", ""); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	err = validateOutputData([]byte("a: [1, 2"), "yaml", "")
+	if err == nil || !strings.Contains(err.Error(), "invalid YAML") {
+		t.Errorf("result: %v", err)
+	}
+	err = validateOutputData([]byte("<a><b></a>"), "xml", "")
+	if err == nil || !strings.Contains(err.Error(), "invalid XML") {
+		t.Errorf("result: %v", err)
+	}
+	err = validateOutputData([]byte("x"), "csv", "")
+	if err == nil || !strings.Contains(err.Error(), "unknown output format: csv") {
+		t.Errorf("result: %v", err)
+	}
+	schema := writeTestFile(t, "schema.json", `{"type": "object", "required": ["id"], "properties": {"id": {"type": "string"}}}`)
+	if err := validateOutputData([]byte(`{"id": "42"}`), "json", schema); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	err = validateOutputData([]byte("id: 42"), "yaml", schema)
+	if err == nil || !strings.Contains(err.Error(), "at '/id': got number, want string") {
+		t.Errorf("result: %v", err)
+	}
+	if err := validateOutputData([]byte("<doc><id>42</id></doc>"), "xml", writeTestFile(t, "doc.json", `{"required": ["doc"]}`)); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	xsdFile := writeTestFile(t, "schema.xsd", testXSD)
+	if err := validateOutputData([]byte("<person><name>John</name><age>30</age></person>"), "xml", xsdFile); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	err = validateOutputData([]byte("<person><name>John</name></person>"), "xml", xsdFile)
+	if err == nil || !strings.Contains(err.Error(), "validateOutput: 1 schema violation(s)") {
+		t.Errorf("result: %v", err)
+	}
+	err = validateOutputData([]byte(`{"id": "42"}`), "json", xsdFile)
+	if err == nil || !strings.Contains(err.Error(), "XSD schema can be used only with xml output") {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestCheckXML(t *testing.T) {
+	if err := checkXML([]byte(`<?xml version="1.0"?><a><b/></a>`)); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if err := checkXML([]byte(`<a></a><b></b>`)); err == nil || !strings.Contains(err.Error(), "found 2") {
+		t.Errorf("result: %v", err)
+	}
+	if err := checkXML([]byte(`<a>&</a>`)); err == nil {
+		t.Errorf("result: %v", err)
+	}
+}
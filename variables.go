@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// tVars template variables defined by repeatable -var key=value parameter
+type tVars map[string]string
+
+// String implements flag.Value
+func (v tVars) String() string {
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + v[key]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set implements flag.Value, parse key=value
+func (v tVars) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("variable must be defined as key=value")
+	}
+	v[strings.TrimSpace(key)] = val
+	return nil
+}
+
+var (
+	templateVars = tVars{} // -var key=value
+	envAllow     []string  // -envallow: allowed environment variables (glob patterns), all allowed if empty
+)
+
+// prepareEnvAllow parse comma separated list of allowed environment variables e.g. "TENANT,BAFI_*"
+func prepareEnvAllow(list string) []string {
+	allow := make([]string, 0)
+	for _, pattern := range strings.Split(list, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			allow = append(allow, pattern)
+		}
+	}
+	return allow
+}
+
+// getVar return template variable defined by -var {{var "company"}}
+func getVar(name string) (string, error) {
+	value, ok := templateVars[name]
+	if !ok {
+		return strictError("", fmt.Errorf("var: variable %s is not defined", name))
+	}
+	return value, nil
+}
+
+// getVars return all template variables defined by -var {{(vars).company}}
+func getVars() map[string]string {
+	return templateVars
+}
+
+// getEnv return environment variable {{env "TENANT"}}. If -envallow is defined only listed variables are accessible
+func getEnv(name string) (string, error) {
+	if len(envAllow) > 0 {
+		allowed := false
+		for _, pattern := range envAllow {
+			if match, _ := path.Match(pattern, name); match {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "", fmt.Errorf("env: variable %s is not allowed (-envallow)", name)
+		}
+	}
+	return os.Getenv(name), nil
+}
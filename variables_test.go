@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTVars(t *testing.T) {
+	vars := tVars{}
+	if err := vars.Set("company=ACME"); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if err := vars.Set("url=https://example.com/?a=b"); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if err := vars.Set("company"); err == nil {
+		t.Errorf("result: %v", err)
+	}
+	if vars.String() != "company=ACME,url=https://example.com/?a=b" {
+		t.Errorf("result: %v", vars.String())
+	}
+}
+
+func TestPrepareEnvAllow(t *testing.T) {
+	result := prepareEnvAllow(" TENANT, BAFI_*,,")
+	if len(result) != 2 || result[0] != "TENANT" || result[1] != "BAFI_*" {
+		t.Errorf("result: %v", result)
+	}
+	if result := prepareEnvAllow(""); len(result) != 0 {
+		t.Errorf("result: %v", result)
+	}
+}
+
+func TestGetVar(t *testing.T) {
+	templateVars = tVars{"company": "ACME"}
+	defer func() { templateVars = tVars{} }()
+	if result, err := getVar("company"); err != nil || result != "ACME" {
+		t.Errorf("result: %v, %v", result, err)
+	}
+	if result, err := renderCall("var", "missing"); err != nil || result != "" {
+		t.Errorf("result: %v, %v", result, err)
+	}
+	if err := runt(`{{var "company"}}-{{(vars).company}}`, "ACME-ACME"); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := getVar("missing"); err == nil || err.Error() != "var: variable missing is not defined" {
+		t.Errorf("result: %v", err)
+	}
+}
+
+func TestGetEnv(t *testing.T) {
+	os.Setenv("BAFI_TEST_TENANT", "tenant1")
+	defer os.Unsetenv("BAFI_TEST_TENANT")
+	if result, err := getEnv("BAFI_TEST_TENANT"); err != nil || result != "tenant1" {
+		t.Errorf("result: %v, %v", result, err)
+	}
+	envAllow = []string{"BAFI_TEST_*"}
+	defer func() { envAllow = nil }()
+	if err := runt(`{{env "BAFI_TEST_TENANT"}}`, "tenant1"); err != nil {
+		t.Errorf("result: %v", err)
+	}
+	if _, err := getEnv("HOME"); err == nil || !strings.Contains(err.Error(), "env: variable HOME is not allowed") {
+		t.Errorf("result: %v", err)
+	}
+}